@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/sql"
+)
+
+type applyOptions struct {
+	dryRun          bool
+	chunkSize       int
+	disableFKChecks bool
+}
+
+// applyTablesData 将差异直接应用到目标库，dryRun 时只统计差异行数
+// 表按外键依赖排序后分两遍执行：先从子表到父表删除，再从父表到子表插入与更新，
+// 避免删除父表行时仍被子表引用、插入子表行时父表行尚未写入
+func applyTablesData(opts *dataDiffOptions, rules []pkgconfig.Rule, applyOpts applyOptions) bool {
+	tables := make(map[string]*conn.Table, len(rules))
+	for _, rule := range rules {
+		tbl, err := opts.tgtDB.ExtractTable(rule.Table)
+		if err != nil || tbl == nil {
+			log.Printf("Error extracting table %s: %v\n", rule.Table, err)
+			continue
+		}
+		tables[rule.Table] = tbl
+	}
+	ok := true
+	sorted := make([]pkgconfig.Rule, 0, len(rules))
+	for _, rule := range sortRulesByForeignKey(rules, tables) {
+		if tables[rule.Table] == nil {
+			ok = false
+			continue
+		}
+		sorted = append(sorted, rule)
+	}
+
+	var summary []string
+	if applyOpts.dryRun {
+		for _, rule := range sorted {
+			line, err := dryRunTableData(opts, rule, tables[rule.Table])
+			if err != nil {
+				log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
+				ok = false
+				continue
+			}
+			summary = append(summary, line)
+		}
+	} else {
+		stats := make(map[string]*sql.ApplyStats, len(sorted))
+		elapsed := make(map[string]time.Duration, len(sorted))
+		failed := make(map[string]bool, len(sorted))
+		apply := func(rule pkgconfig.Rule, phase sql.ApplyPhase) {
+			if failed[rule.Table] {
+				return
+			}
+			start := time.Now()
+			st, err := applyTableData(opts, rule, tables[rule.Table], applyOpts, phase)
+			elapsed[rule.Table] += time.Since(start)
+			if stats[rule.Table] == nil {
+				stats[rule.Table] = &sql.ApplyStats{}
+			}
+			stats[rule.Table].Deleted += st.Deleted
+			stats[rule.Table].Inserted += st.Inserted
+			stats[rule.Table].Updated += st.Updated
+			if err != nil {
+				log.Printf("Error applying data for table %s: %v\n", rule.Table, err)
+				failed[rule.Table] = true
+				ok = false
+			}
+		}
+		for _, rule := range slices.Backward(sorted) {
+			apply(rule, sql.ApplyDeletes)
+		}
+		for _, rule := range sorted {
+			apply(rule, sql.ApplyWrites)
+		}
+		for _, rule := range sorted {
+			st := stats[rule.Table]
+			if failed[rule.Table] {
+				log.Printf("Table %s partially applied: deleted %d, inserted %d, updated %d\n", rule.Table, st.Deleted, st.Inserted, st.Updated)
+				continue
+			}
+			summary = append(summary, fmt.Sprintf("%s: deleted %d, inserted %d, updated %d (%v)",
+				rule.Table, st.Deleted, st.Inserted, st.Updated, elapsed[rule.Table]))
+		}
+	}
+	log.Println("Summary:")
+	for _, line := range summary {
+		log.Println("  " + line)
+	}
+	return ok
+}
+
+func dryRunTableData(opts *dataDiffOptions, rule pkgconfig.Rule, tbl *conn.Table) (string, error) {
+	start := time.Now()
+	compareRule, err := diff.CreateCompareRuleFromConfig(tbl, rule)
+	if err != nil {
+		return "", err
+	}
+	stats := &diff.DiffStats{}
+	if err := compareTable(opts, compareRule, stats); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: to delete %d, to insert %d, to update %d (dry run, %v)",
+		rule.Table, stats.Dropped, stats.Added, stats.Modified, time.Since(start)), nil
+}
+
+// applyTableData 比对一张表并执行 phase 指定的差异，返回已提交的影响行数
+func applyTableData(opts *dataDiffOptions, rule pkgconfig.Rule, tbl *conn.Table, applyOpts applyOptions, phase sql.ApplyPhase) (sql.ApplyStats, error) {
+	compareRule, err := diff.CreateCompareRuleFromConfig(tbl, rule)
+	if err != nil {
+		return sql.ApplyStats{}, err
+	}
+	if err := checkUpsertKey(opts, tbl, compareRule.GetKeyColumns()); err != nil {
+		return sql.ApplyStats{}, err
+	}
+	sink := sql.NewApplyDiffSink(opts.tgtDB.GetConn(), opts.dialect, tbl, sql.ApplyOptions{
+		UpdateCols:      compareRule.GetColumns(),
+		KeyColumns:      compareRule.GetKeyColumns(),
+		Upsert:          opts.upsert,
+		ChunkSize:       applyOpts.chunkSize,
+		DisableFKChecks: applyOpts.disableFKChecks,
+		Phase:           phase,
+		TmpDir:          opts.tmpDir,
+	})
+	// 差异先暂存，比对结束后再执行，避免边读目标表边修改；比对出错时不执行
+	if err = compareTable(opts, compareRule, sink); err == nil {
+		err = sink.Close()
+	}
+	return sink.Stats(), err
+}
+
+// sortRulesByForeignKey 按外键依赖对规则排序，被引用的表排在前面
+// 存在循环依赖时剩余的表保持原有顺序
+func sortRulesByForeignKey(rules []pkgconfig.Rule, tables map[string]*conn.Table) []pkgconfig.Rule {
+	deps := make(map[string][]string, len(rules))
+	for _, rule := range rules {
+		tbl := tables[rule.Table]
+		if tbl == nil {
+			continue
+		}
+		for _, fk := range tbl.ForeignKeys {
+			if fk.ReferencedTable != rule.Table && tables[fk.ReferencedTable] != nil {
+				deps[rule.Table] = append(deps[rule.Table], fk.ReferencedTable)
+			}
+		}
+	}
+	sorted := make([]pkgconfig.Rule, 0, len(rules))
+	done := make(map[string]bool, len(rules))
+	remaining := slices.Clone(rules)
+	for len(remaining) > 0 {
+		progressed := false
+		next := remaining[:0]
+		for _, rule := range remaining {
+			ready := true
+			for _, dep := range deps[rule.Table] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, rule)
+				done[rule.Table] = true
+				progressed = true
+			} else {
+				next = append(next, rule)
+			}
+		}
+		remaining = next
+		if !progressed {
+			return append(sorted, remaining...)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,241 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jacktea/data-smith/internal/config"
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/db"
+	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/report"
+	"github.com/jacktea/data-smith/pkg/sql"
+
+	"github.com/spf13/cobra"
+)
+
+// check 命令的退出码
+const (
+	exitNoDrift = 0
+	exitDrift   = 1
+	exitError   = 2
+)
+
+// ExitError 携带退出码的命令错误，Err 为 nil 时原因已输出到日志
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check schema (and data) drift between source and target DB for CI",
+	Long: `Check schema drift, and data drift of the tables in the rules file when given.
+Prints a summary to stdout and exits with 0 when no drift is found, 1 when drift is found and 2 on errors.`,
+	// 参数与选项错误同样以 2 退出，避免被当作存在漂移
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.NoArgs(cmd, args); err != nil {
+			return &ExitError{Code: exitError, Err: err}
+		}
+		return nil
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if code := runCheck(cmd); code != exitNoDrift {
+			return &ExitError{Code: code}
+		}
+		return nil
+	},
+}
+
+func runCheck(cmd *cobra.Command) int {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		log.Println(`Error: required flag(s) "config" not set`)
+		return exitError
+	}
+	rulesPath, _ := cmd.Flags().GetString("rules")
+	checkSchema, _ := cmd.Flags().GetBool("schema")
+	maxRows, _ := cmd.Flags().GetInt64("max-rows")
+	ignore, _ := cmd.Flags().GetStringSlice("ignore")
+	if ignoreFile, _ := cmd.Flags().GetString("ignore-file"); ignoreFile != "" {
+		patterns, err := loadIgnoreFile(ignoreFile)
+		if err != nil {
+			log.Println("Error loading ignore file:", err)
+			return exitError
+		}
+		ignore = append(ignore, patterns...)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		return exitError
+	}
+	filter, err := schemaFilter(cmd, cfg)
+	if err != nil {
+		log.Println("Error parsing schema filter:", err)
+		return exitError
+	}
+	var rules *pkgconfig.RuleSet
+	if rulesPath != "" {
+		if rules, err = config.LoadRules(rulesPath); err != nil {
+			log.Println("Error loading rules:", err)
+			return exitError
+		}
+	}
+	srcDB, err := db.NewDBAdapter(&cfg.SourceDB)
+	if err != nil {
+		log.Println("Error connecting to source DB:", err)
+		return exitError
+	}
+	defer srcDB.Close()
+	tgtDB, err := db.NewDBAdapter(&cfg.TargetDB)
+	if err != nil {
+		log.Println("Error connecting to target DB:", err)
+		return exitError
+	}
+	defer tgtDB.Close()
+
+	drift, failed := false, false
+	if checkSchema {
+		schemaDiff, err := diff.CompareSchemasWithAdapter(srcDB, tgtDB, filter)
+		if err != nil {
+			log.Println("Error comparing schemas:", err)
+			fmt.Println("schema: error")
+			failed = true
+		} else {
+			r := report.NewSchemaReport(schemaDiff).Ignore(ignore)
+			if r.IsEmpty() {
+				fmt.Println("schema: no drift")
+			} else {
+				drift = true
+				fmt.Printf("schema: %d tables differ\n", len(r.Tables))
+				for _, t := range r.Tables {
+					fmt.Printf("  %s %s %s\n", t.Action, t.Type, t.Table)
+					if t.Action == diff.DiffTypeModify {
+						for _, c := range t.Changes {
+							fmt.Printf("    %s %s %s\n", c.Action, c.Object, c.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if rules != nil {
+		opts := &dataDiffOptions{srcDB: srcDB, tgtDB: tgtDB, dialect: sql.NewDialect(cfg.TargetDB.Type)}
+		opts.batchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.checksum, _ = cmd.Flags().GetBool("checksum")
+		opts.bisectionFactor = diff.DefaultBisectionFactor
+		opts.bisectionThreshold = diff.DefaultBisectionThreshold
+		for _, rule := range rules.Rules {
+			if matchIgnore(ignore, rule.Table) {
+				continue
+			}
+			stats, err := checkTableData(opts, rule)
+			switch {
+			case err != nil:
+				log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
+				fmt.Printf("data: %s: error\n", rule.Table)
+				failed = true
+			case stats.Total() > maxRows:
+				drift = true
+				fmt.Printf("data: %s: added %d, dropped %d, modified %d\n", rule.Table, stats.Added, stats.Dropped, stats.Modified)
+			case stats.Total() > 0:
+				fmt.Printf("data: %s: %d rows differ, within max rows %d\n", rule.Table, stats.Total(), maxRows)
+			default:
+				fmt.Printf("data: %s: no drift\n", rule.Table)
+			}
+		}
+	}
+
+	switch {
+	case failed:
+		fmt.Println("result: error")
+		return exitError
+	case drift:
+		fmt.Println("result: drift found")
+		return exitDrift
+	}
+	fmt.Println("result: no drift")
+	return exitNoDrift
+}
+
+func checkTableData(opts *dataDiffOptions, rule pkgconfig.Rule) (*diff.DiffStats, error) {
+	tgtTable, err := opts.tgtDB.ExtractTable(rule.Table)
+	if err != nil {
+		return nil, err
+	}
+	if tgtTable == nil {
+		return nil, fmt.Errorf("table %s not found", rule.Table)
+	}
+	compareRule, err := diff.CreateCompareRuleFromConfig(tgtTable, rule)
+	if err != nil {
+		return nil, err
+	}
+	stats := &diff.DiffStats{}
+	if err := compareTable(opts, compareRule, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// matchIgnore 表名是否匹配忽略列表，规则与结构报告的忽略规则一致
+func matchIgnore(patterns []string, table string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIgnoreFile 读取忽略列表，每行一个模式，# 开头的行为注释
+func loadIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+func init() {
+	checkCmd.Flags().StringP("config", "c", "", "Path to config file (required)")
+	checkCmd.Flags().StringP("rules", "r", "", "Path to rules file, data of the tables in it is also checked when given")
+	checkCmd.Flags().Bool("schema", true, "Check schema drift")
+	checkCmd.Flags().StringSlice("ignore", nil, "Known differences to ignore: table patterns like audit_* or table.object patterns like users.updated_at")
+	checkCmd.Flags().String("ignore-file", "", "File of ignore patterns, one per line")
+	checkCmd.Flags().Int64("max-rows", 0, "Differing rows tolerated per table before reporting data drift")
+	checkCmd.Flags().Int("batch-size", 1000, "Batch size for data diff")
+	checkCmd.Flags().Bool("checksum", false, "Compare checksums of primary key ranges for data diff")
+	addSchemaFilterFlags(checkCmd)
+	checkCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &ExitError{Code: exitError, Err: err}
+	})
+}
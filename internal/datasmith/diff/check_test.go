@@ -0,0 +1,26 @@
+package diff
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCheckArgumentErrorsExitCode(t *testing.T) {
+	checkCmd.SetOut(io.Discard)
+	checkCmd.SetErr(io.Discard)
+	tests := [][]string{
+		{"--bogus"},
+		{"--max-rows", "abc"},
+		{"extra"},
+		{},
+	}
+	for _, args := range tests {
+		checkCmd.SetArgs(args)
+		err := checkCmd.Execute()
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != exitError {
+			t.Errorf("args %v: expect exit code %d, got %v", args, exitError, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+// diffProgress 数据比对的进度文件内容
+type diffProgress struct {
+	// Offset 输出文件中已确认写入的长度，续传时截断到该位置
+	Offset int64                     `json:"offset"`
+	Tables map[string]*tableProgress `json:"tables"`
+}
+
+type tableProgress struct {
+	Done       bool             `json:"done"`
+	Checkpoint *diff.Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// checkpointer 在每批数据输出后保存进度，进程中断后可从最后的断点继续
+type checkpointer struct {
+	path  string
+	out   *os.File
+	state diffProgress
+}
+
+// newCheckpointer 创建进度记录，resume 为 true 时读取已有进度并将输出文件截断到最后确认的位置
+func newCheckpointer(path string, out *os.File, resume bool) (*checkpointer, error) {
+	c := &checkpointer{path: path, out: out, state: diffProgress{Tables: map[string]*tableProgress{}}}
+	if resume {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &c.state); err != nil {
+				return nil, err
+			}
+			if c.state.Tables == nil {
+				c.state.Tables = map[string]*tableProgress{}
+			}
+		}
+	}
+	if err := c.rollback(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checkpointer) table(name string) *tableProgress {
+	tp := c.state.Tables[name]
+	if tp == nil {
+		tp = &tableProgress{}
+		c.state.Tables[name] = tp
+	}
+	return tp
+}
+
+// save 记录输出文件当前位置与表的断点，调用前输出缓冲需已刷新
+func (c *checkpointer) save(table string, cp *diff.Checkpoint, done bool) error {
+	if err := c.out.Sync(); err != nil {
+		return err
+	}
+	offset, err := c.out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	c.state.Offset = offset
+	tp := c.table(table)
+	tp.Checkpoint = cp
+	tp.Done = done
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免中断时进度文件损坏
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// rollback 将输出文件截断到最后保存的位置
+func (c *checkpointer) rollback() error {
+	if err := c.out.Truncate(c.state.Offset); err != nil {
+		return err
+	}
+	_, err := c.out.Seek(c.state.Offset, io.SeekStart)
+	return err
+}
+
+// remove 全部完成后删除进度文件
+func (c *checkpointer) remove() {
+	os.Remove(c.path)
+}
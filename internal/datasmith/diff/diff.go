@@ -7,4 +7,6 @@ func Install(root *cobra.Command) {
 	root.AddCommand(diffSchemaCmd)
 	// 对比数据库数据
 	root.AddCommand(diffDataCmd)
+	// 检查结构与数据漂移，按结果返回退出码
+	root.AddCommand(checkCmd)
 }
@@ -2,13 +2,20 @@ package diff
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/jacktea/data-smith/internal/config"
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
 	"github.com/jacktea/data-smith/pkg/db"
 	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/report"
 	"github.com/jacktea/data-smith/pkg/sql"
 
 	"github.com/spf13/cobra"
@@ -20,17 +27,28 @@ var diffDataCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		configPath, _ := cmd.Flags().GetString("config")
 		rulesPath, _ := cmd.Flags().GetString("rules")
-		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		quick, _ := cmd.Flags().GetBool("quick")
+		// 只有快速比对可以不指定规则文件
+		if rulesPath == "" && !quick {
+			log.Println(`Error: required flag "rules" not set (only --quick can run without a rules file)`)
+			os.Exit(1)
+		}
 
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
 			log.Println("Error loading config:", err)
 			os.Exit(1)
 		}
-		rules, err := config.LoadRules(rulesPath)
-		if err != nil {
-			log.Println("Error loading rules:", err)
-			os.Exit(1)
+		formatName, _ := cmd.Flags().GetString("format")
+		// 快速比对未指定规则文件时比对全部表
+		rules := &pkgconfig.RuleSet{}
+		if rulesPath != "" {
+			rules, err = config.LoadRules(rulesPath)
+			if err != nil {
+				log.Println("Error loading rules:", err)
+				os.Exit(1)
+			}
 		}
 
 		srcDB, err := db.NewDBAdapter(&cfg.SourceDB)
@@ -46,7 +64,34 @@ var diffDataCmd = &cobra.Command{
 		}
 		defer tgtDB.Close()
 
-		dbDialect := sql.NewDialect(cfg.TargetDB.Type)
+		opts := &dataDiffOptions{
+			srcDB:   srcDB,
+			tgtDB:   tgtDB,
+			dialect: sql.NewDialect(cfg.TargetDB.Type),
+		}
+		opts.batchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.checksum, _ = cmd.Flags().GetBool("checksum")
+		opts.bisectionFactor, _ = cmd.Flags().GetInt("bisection-factor")
+		opts.bisectionThreshold, _ = cmd.Flags().GetInt64("bisection-threshold")
+		insertFormat, _ := cmd.Flags().GetString("insert-format")
+		opts.insertFormat = sql.InsertFormat(insertFormat)
+		switch opts.insertFormat {
+		case sql.InsertFormatRow, sql.InsertFormatBatch, sql.InsertFormatCopy:
+		default:
+			log.Printf("Invalid insert format %q, expected row, batch or copy\n", insertFormat)
+			os.Exit(1)
+		}
+		opts.sqlBatchSize, _ = cmd.Flags().GetInt("sql-batch-size")
+		opts.targetType = cfg.TargetDB.Type
+		mode, _ := cmd.Flags().GetString("mode")
+		switch mode {
+		case modeDefault:
+		case modeUpsert:
+			opts.upsert = true
+		default:
+			log.Printf("Invalid mode %q, expected %s or %s\n", mode, modeDefault, modeUpsert)
+			os.Exit(1)
+		}
 
 		// diff dir 设定为当前程序的执行目录
 		diffDir, err := os.Getwd()
@@ -54,77 +99,346 @@ var diffDataCmd = &cobra.Command{
 			log.Println("Error getting current working directory:", err)
 			os.Exit(1)
 		}
+		opts.tmpDir = diffDir
+
+		if quick {
+			if rulesPath == "" {
+				filter := cfg.SchemaFilter
+				if rules.Rules, err = rulesFromSchema(srcDB, tgtDB, &filter); err != nil {
+					log.Println("Error reading schema:", err)
+					os.Exit(1)
+				}
+			}
+			if !quickTablesData(opts, rules.Rules, filepath.Join(diffDir, "data_quick.txt"), filepath.Join(diffDir, "data_quick_rules.json")) {
+				os.Exit(1)
+			}
+			return
+		}
+
+		format, err := report.ParseFormat(formatName)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		if format != report.FormatSQL {
+			limit, _ := cmd.Flags().GetInt("report-limit")
+			if !reportTablesData(opts, rules.Rules, format, limit, filepath.Join(diffDir, "data_diff."+format.Ext())) {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if apply, _ := cmd.Flags().GetBool("apply"); apply {
+			applyOpts := applyOptions{}
+			applyOpts.dryRun, _ = cmd.Flags().GetBool("dry-run")
+			applyOpts.chunkSize, _ = cmd.Flags().GetInt("chunk-size")
+			applyOpts.disableFKChecks, _ = cmd.Flags().GetBool("disable-fk-checks")
+			if parallel > 1 {
+				log.Println("Parallel is ignored in apply mode, tables are applied in foreign key order")
+			}
+			if !applyTablesData(opts, rules.Rules, applyOpts) {
+				os.Exit(1)
+			}
+			return
+		}
+
 		diffFile := fmt.Sprintf("%s/data_diff.sql", diffDir)
 		log.Printf("Diff file: %s\n", diffFile)
-		sqlFile, err := os.Create(diffFile)
+		resume, _ := cmd.Flags().GetBool("resume")
+		parallel = limitParallel(parallel, srcDB, tgtDB)
+		if resume && parallel > 1 {
+			log.Println("Parallel is ignored when resuming, tables are compared sequentially")
+			parallel = 1
+		}
+		var sqlFile *os.File
+		if resume {
+			sqlFile, err = os.OpenFile(diffFile, os.O_RDWR|os.O_CREATE, 0644)
+		} else {
+			sqlFile, err = os.Create(diffFile)
+		}
 		if err != nil {
 			log.Println("Error creating sql file:", err)
 			os.Exit(1)
 		}
 		defer sqlFile.Close()
 
-		for _, rule := range rules.Rules {
-			tgtTable, err := tgtDB.ExtractTable(rule.Table)
-			if err != nil || tgtTable == nil {
-				log.Printf("Error extracting table %s: %v\n", rule.Table, err)
-				continue
-			}
-			log.Printf("Start comparing data for table %s\n", rule.Table)
-			sqlFile.WriteString(fmt.Sprintf("--- diff %s \n", rule.Table))
-			start := time.Now()
-			diff, err := diff.StreamCompareDataToDiff(
-				srcDB,
-				tgtDB,
-				diff.CreateCompareRule(tgtTable, rule.ComparisonKey),
-				batchSize,
-			)
-			if err != nil {
-				log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
-				continue
+		if parallel <= 1 {
+			// 指定 --resume 时定期保存断点，中断后再次使用 --resume 从断点继续
+			var cp *checkpointer
+			if resume {
+				cp, err = newCheckpointer(fmt.Sprintf("%s/data_diff.checkpoint.json", diffDir), sqlFile, true)
+				if err != nil {
+					log.Println("Error loading checkpoint:", err)
+					os.Exit(1)
+				}
+				opts.checkpointRows, _ = cmd.Flags().GetInt("checkpoint-rows")
 			}
-			log.Printf("Time taken: %v\n", time.Since(start))
-			for _, row := range diff.Dropped {
-				sqlFile.WriteString(dbDialect.GenerateDeleteSql(tgtTable, row) + "\n")
+			ok := true
+			for _, rule := range rules.Rules {
+				ok = diffTableData(opts, rule, sqlFile, cp) && ok
 			}
-			for _, row := range diff.Added {
-				sqlFile.WriteString(dbDialect.GenerateInsertSql(tgtTable, row) + "\n")
+			if !ok {
+				if cp != nil {
+					log.Println("Some tables failed, run with --resume again to retry them")
+				}
+				sqlFile.Close()
+				os.Exit(1)
 			}
-			for _, row := range diff.Modified {
-				sqlFile.WriteString(dbDialect.GenerateUpdateSql(tgtTable, row.New, rule.ComparisonKey) + "\n")
+			if cp != nil {
+				cp.remove()
 			}
-
-			// err = diff.StreamCompareData(
-			// 	srcDB,
-			// 	tgtDB,
-			// 	diff.CreateCompareRule(tgtTable, rule.ComparisonKey),
-			// 	batchSize,
-			// 	func(diffType diff.DiffType, srcRow, tgtRow conn.Record) {
-			// 		var str string
-			// 		switch diffType {
-			// 		case diff.DiffTypeAdd:
-			// 			str = dbDialect.GenerateInsertSql(tgtTable, srcRow)
-			// 			sqlFile.WriteString(str + "\n")
-			// 		case diff.DiffTypeDrop:
-			// 			str = dbDialect.GenerateDeleteSql(tgtTable, tgtRow)
-			// 			sqlFile.WriteString(str + "\n")
-			// 		case diff.DiffTypeModify:
-			// 			str = dbDialect.GenerateUpdateSql(tgtTable, srcRow, rule.ComparisonKey)
-			// 			sqlFile.WriteString(str + "\n")
-			// 		}
-			// 	})
-			// log.Printf("Time taken: %v\n", time.Since(start))
-			// if err != nil {
-			// 	log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
-			// 	continue
-			// }
+			return
+		}
+		ok, err := diffTablesParallel(opts, rules.Rules, parallel, sqlFile)
+		if err != nil {
+			log.Println("Error writing sql file:", err)
+			os.Exit(1)
+		}
+		if !ok {
+			sqlFile.Close()
+			os.Exit(1)
 		}
 	},
 }
 
 func init() {
 	diffDataCmd.Flags().StringP("config", "c", "", "Path to config file")
-	diffDataCmd.Flags().StringP("rules", "r", "", "Path to rules file")
+	diffDataCmd.Flags().StringP("rules", "r", "", "Path to rules file (required unless --quick)")
 	diffDataCmd.Flags().Int("batch-size", 1000, "Batch size for data diff and SQL output")
+	diffDataCmd.Flags().Bool("checksum", false, "Compare checksums of primary key ranges in database and only fetch differing ranges")
+	diffDataCmd.Flags().Int("bisection-factor", diff.DefaultBisectionFactor, "Number of segments to split a differing range into (checksum mode)")
+	diffDataCmd.Flags().Int64("bisection-threshold", diff.DefaultBisectionThreshold, "Fetch rows directly when a differing range has at most this many rows (checksum mode)")
+	diffDataCmd.Flags().IntP("parallel", "p", 1, "Number of tables to compare concurrently")
+	diffDataCmd.Flags().String("insert-format", string(sql.InsertFormatRow), "Output format of added rows: row (one INSERT per row), batch (multi-row INSERT) or copy (COPY FROM stdin for postgres, LOAD DATA with a TSV file for mysql)")
+	diffDataCmd.Flags().Int("sql-batch-size", 500, "Rows per INSERT/COPY/DELETE statement when insert-format is batch or copy")
+	diffDataCmd.Flags().Bool("apply", false, "Apply the differences to the target DB directly instead of generating a SQL file")
+	diffDataCmd.Flags().Bool("dry-run", false, "Only count the rows to delete/insert/update (apply mode)")
+	diffDataCmd.Flags().Int("chunk-size", 1000, "Rows per transaction (apply mode)")
+	diffDataCmd.Flags().Bool("disable-fk-checks", false, "Disable foreign key checks inside each transaction (apply mode; postgres requires superuser)")
+	diffDataCmd.Flags().String("format", string(report.FormatSQL), "Output format: sql, or a report in json, csv, markdown or html")
+	diffDataCmd.Flags().Int("report-limit", 1000, "Max rows of each kind (added/dropped/modified) listed per table in reports")
+	diffDataCmd.Flags().Bool("quick", false, "Only compare row counts, key ranges and statsColumns aggregates; compares all tables when no rules file is given")
+	diffDataCmd.Flags().Bool("resume", false, "Save checkpoints while comparing, and continue an interrupted diff from the last checkpoint, appending to the existing data_diff.sql")
+	diffDataCmd.Flags().Int("checkpoint-rows", 100000, "Rows compared between checkpoints (with --resume)")
+	diffDataCmd.Flags().String("mode", modeDefault, "SQL output mode: default (INSERT/UPDATE/DELETE) or upsert (idempotent INSERT ... ON CONFLICT/ON DUPLICATE KEY UPDATE)")
 	diffDataCmd.MarkFlagRequired("config")
-	diffDataCmd.MarkFlagRequired("rules")
+}
+
+const (
+	modeDefault = "default"
+	modeUpsert  = "upsert"
+)
+
+type dataDiffOptions struct {
+	srcDB              conn.DBAdapter
+	tgtDB              conn.DBAdapter
+	dialect            sql.IDialect
+	batchSize          int
+	checksum           bool
+	bisectionFactor    int
+	bisectionThreshold int64
+	upsert             bool
+	insertFormat       sql.InsertFormat
+	sqlBatchSize       int
+	targetType         consts.DBType
+	tmpDir             string
+	checkpointRows     int
+}
+
+// limitParallel 并发数不超过两端连接池的最大连接数
+// 每张表在每一端同一时刻只占用一个连接
+func limitParallel(parallel int, adapters ...conn.DBAdapter) int {
+	for _, a := range adapters {
+		maxOpen := a.GetConn().Stats().MaxOpenConnections
+		if maxOpen > 0 && parallel > maxOpen {
+			log.Printf("Parallel %d exceeds max open connections of %s DB, limited to %d\n", parallel, a.GetConfig().Type, maxOpen)
+			parallel = maxOpen
+		}
+	}
+	return parallel
+}
+
+// diffTablesParallel 使用工作池并发比对多张表
+// 每张表的输出先写入独立的临时文件，全部完成后按规则顺序合并，保证脚本内容确定
+// 有表比对失败时返回 false，已成功的表仍会写入 w
+func diffTablesParallel(opts *dataDiffOptions, rules []pkgconfig.Rule, parallel int, w io.Writer) (bool, error) {
+	parts := make([]string, len(rules))
+	errs := make([]error, len(rules))
+	oks := make([]bool, len(rules))
+	defer func() {
+		for _, name := range parts {
+			if name != "" {
+				os.Remove(name)
+			}
+		}
+	}()
+
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				f, err := os.CreateTemp(opts.tmpDir, ".data_diff_*.sql")
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				parts[i] = f.Name()
+				oks[i] = diffTableData(opts, rules[i], f, nil)
+				errs[i] = f.Close()
+			}
+		}()
+	}
+	for i := range rules {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	ok := true
+	for i, name := range parts {
+		if errs[i] != nil {
+			return false, errs[i]
+		}
+		if err := appendFile(w, name); err != nil {
+			return false, err
+		}
+		ok = ok && oks[i]
+	}
+	return ok, nil
+}
+
+// copyDataFile MySQL 的 copy 格式需要独立的 TSV 数据文件，PostgreSQL 内联到脚本
+// 续传时使用新的文件名，避免覆盖断点之前输出的脚本引用的数据文件
+func copyDataFile(opts *dataDiffOptions, table string, from *diff.Checkpoint) string {
+	if opts.insertFormat != sql.InsertFormatCopy || opts.targetType != consts.DBTypeMySQL {
+		return ""
+	}
+	if from != nil && from.SrcRows+from.TgtRows > 0 {
+		return filepath.Join(opts.tmpDir, fmt.Sprintf("data_diff_%s_r%d.tsv", table, from.SrcRows+from.TgtRows))
+	}
+	return filepath.Join(opts.tmpDir, fmt.Sprintf("data_diff_%s.tsv", table))
+}
+
+func appendFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// diffTableData 比对单张表的数据并将 SQL 写入 w，出错时记录日志并返回 false
+// cp 不为 nil 时每批输出后保存断点，已完成的表直接跳过，未完成的表从断点继续
+func diffTableData(opts *dataDiffOptions, rule pkgconfig.Rule, w io.Writer, cp *checkpointer) bool {
+	var tp *tableProgress
+	if cp != nil {
+		tp = cp.table(rule.Table)
+		if tp.Done {
+			log.Printf("Table %s already compared, skipped\n", rule.Table)
+			return true
+		}
+	}
+	tgtTable, err := opts.tgtDB.ExtractTable(rule.Table)
+	if err != nil || tgtTable == nil {
+		log.Printf("Error extracting table %s: %v\n", rule.Table, err)
+		return false
+	}
+	compareRule, err := diff.CreateCompareRuleFromConfig(tgtTable, rule)
+	if err != nil {
+		log.Printf("Error creating compare rule for table %s: %v\n", rule.Table, err)
+		return false
+	}
+	if err := checkUpsertKey(opts, tgtTable, compareRule.GetKeyColumns()); err != nil {
+		log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
+		return false
+	}
+	start := time.Now()
+	var from *diff.Checkpoint
+	if tp != nil && tp.Checkpoint != nil {
+		from = tp.Checkpoint
+		log.Printf("Resume comparing data for table %s after %d source rows and %d target rows\n", rule.Table, from.SrcRows, from.TgtRows)
+	} else {
+		log.Printf("Start comparing data for table %s\n", rule.Table)
+		io.WriteString(w, fmt.Sprintf("--- diff %s \n", rule.Table))
+		if cp != nil {
+			from = &diff.Checkpoint{}
+			if err := cp.save(rule.Table, from, false); err != nil {
+				log.Printf("Error saving checkpoint for table %s: %v\n", rule.Table, err)
+				return false
+			}
+		}
+	}
+	sink := sql.NewSqlDiffSink(w, opts.dialect, tgtTable, sql.SqlSinkOptions{
+		UpdateCols:   compareRule.GetColumns(),
+		KeyColumns:   compareRule.GetKeyColumns(),
+		Upsert:       opts.upsert,
+		InsertFormat: opts.insertFormat,
+		BatchSize:    opts.sqlBatchSize,
+		CopyDataFile: copyDataFile(opts, rule.Table, from),
+		FlushEvery:   opts.batchSize,
+		TmpDir:       opts.tmpDir,
+	})
+	if cp != nil && !opts.checksum {
+		err = diff.StreamCompareDataResumable(opts.srcDB, opts.tgtDB, compareRule, opts.batchSize, sink, diff.ResumeOptions{
+			From:  from,
+			Every: opts.checkpointRows,
+			Save: func(c *diff.Checkpoint) error {
+				return cp.save(rule.Table, c, false)
+			},
+		})
+	} else {
+		// 校验和模式只记录表级别的完成状态
+		err = compareTable(opts, compareRule, sink)
+	}
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && cp != nil {
+		err = cp.save(rule.Table, nil, true)
+	}
+	if err != nil {
+		log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
+		if cp != nil {
+			// 丢弃断点之后的部分输出，续传时从断点重新比对
+			if err := cp.rollback(); err != nil {
+				log.Printf("Error truncating sql file: %v\n", err)
+			}
+		}
+		return false
+	}
+	stats := sink.Stats()
+	if from != nil {
+		// 续传时累计断点之前已输出的差异
+		stats.Added += from.Stats.Added
+		stats.Dropped += from.Stats.Dropped
+		stats.Modified += from.Stats.Modified
+	}
+	log.Printf("Table %s time taken: %v, added: %d, dropped: %d, modified: %d\n", rule.Table, time.Since(start), stats.Added, stats.Dropped, stats.Modified)
+	return true
+}
+
+// checkUpsertKey upsert 依赖键冲突定位行，规则指定的键列必须是目标表的主键或唯一索引，
+// 否则 PostgreSQL 的 ON CONFLICT 会报错，MySQL 会按其他唯一键更新错误的行
+func checkUpsertKey(opts *dataDiffOptions, tbl *conn.Table, keys []string) error {
+	if !opts.upsert || len(keys) == 0 || tbl.IsUniqueKey(keys) {
+		return nil
+	}
+	return fmt.Errorf("upsert mode requires keyColumns %v to match the primary key or a unique index of table %s", keys, tbl.Name)
+}
+
+// compareTable 按选项选择逐行或校验和分段比对，差异写入 sink
+func compareTable(opts *dataDiffOptions, compareRule diff.ICompareRule, sink diff.DiffSink) error {
+	if opts.checksum {
+		return diff.ChecksumCompareDataToSink(opts.srcDB, opts.tgtDB, compareRule, diff.ChecksumOptions{
+			BisectionFactor:    opts.bisectionFactor,
+			BisectionThreshold: opts.bisectionThreshold,
+			BatchSize:          opts.batchSize,
+		}, sink)
+	}
+	return diff.StreamCompareDataToSink(opts.srcDB, opts.tgtDB, compareRule, opts.batchSize, sink)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/jacktea/data-smith/internal/config"
 	"github.com/jacktea/data-smith/pkg/db"
 	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/report"
 	"github.com/jacktea/data-smith/pkg/sql"
 
 	"github.com/spf13/cobra"
@@ -19,6 +20,12 @@ var diffSchemaCmd = &cobra.Command{
 	Short: "Compare database schemas and generate SQL diff",
 	Run: func(cmd *cobra.Command, args []string) {
 		configPath, _ := cmd.Flags().GetString("config")
+		formatName, _ := cmd.Flags().GetString("format")
+		format, err := report.ParseFormat(formatName)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
@@ -26,6 +33,12 @@ var diffSchemaCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		filter, err := schemaFilter(cmd, cfg)
+		if err != nil {
+			log.Println("Error parsing schema filter:", err)
+			os.Exit(1)
+		}
+
 		srcDB, err := db.NewDBAdapter(&cfg.SourceDB)
 		if err != nil {
 			log.Println("Error connecting to source DB:", err)
@@ -40,18 +53,33 @@ var diffSchemaCmd = &cobra.Command{
 		defer tgtDB.Close()
 		start := time.Now()
 		log.Printf("Start comparing schemas\n")
-		diff, err := diff.CompareSchemasWithAdapter(srcDB, tgtDB)
+		diff, err := diff.CompareSchemasWithAdapter(srcDB, tgtDB, filter)
 		if err != nil {
 			log.Println("Error comparing schemas:", err)
 			os.Exit(1)
 		}
 		log.Printf("Schemas compared successfully, time taken: %v\n", time.Since(start))
-		sqls := sql.GenerateSchemaSQL(diff, cfg.TargetDB.Type)
 		diffDir, err := os.Getwd()
 		if err != nil {
 			log.Println("Error getting current working directory:", err)
 			os.Exit(1)
 		}
+		if format != report.FormatSQL {
+			reportFile := fmt.Sprintf("%s/schema_diff.%s", diffDir, format.Ext())
+			log.Printf("Report file: %s\n", reportFile)
+			f, err := os.Create(reportFile)
+			if err != nil {
+				log.Println("Error creating report file:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			if err := report.WriteSchema(f, format, report.NewSchemaReport(diff)); err != nil {
+				log.Println("Error writing report file:", err)
+				os.Exit(1)
+			}
+			return
+		}
+		sqls := sql.GenerateSchemaSQL(diff, cfg.TargetDB.Type)
 		diffFile := fmt.Sprintf("%s/schema_diff.sql", diffDir)
 		log.Printf("Diff file: %s\n", diffFile)
 		sqlFile, err := os.Create(diffFile)
@@ -70,5 +98,7 @@ var diffSchemaCmd = &cobra.Command{
 
 func init() {
 	diffSchemaCmd.Flags().StringP("config", "c", "", "Path to config file")
+	diffSchemaCmd.Flags().String("format", string(report.FormatSQL), "Output format: sql, or a report in json, csv, markdown or html")
+	addSchemaFilterFlags(diffSchemaCmd)
 	diffSchemaCmd.MarkFlagRequired("config")
 }
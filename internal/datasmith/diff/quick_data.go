@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+// quickTablesData 快速比对各表的统计信息并输出报告，
+// 结论为 different 或 suspicious 的表写入规则文件，供后续完整比对使用
+func quickTablesData(opts *dataDiffOptions, rules []pkgconfig.Rule, reportFile, rulesFile string) bool {
+	ok := true
+	var report strings.Builder
+	flagged := pkgconfig.RuleSet{Rules: []pkgconfig.Rule{}}
+	counts := map[diff.QuickStatus]int{}
+	for _, rule := range rules {
+		result, err := quickTableData(opts, rule)
+		if err != nil {
+			log.Printf("Error checking table %s: %v\n", rule.Table, err)
+			fmt.Fprintf(&report, "%-10s %s: %v\n", "error", rule.Table, err)
+			ok = false
+			continue
+		}
+		counts[result.Status]++
+		line := fmt.Sprintf("%-10s %s: rows %d/%d", result.Status, rule.Table, result.Src.RowCount, result.Tgt.RowCount)
+		if len(result.Reasons) > 0 {
+			line += ", " + strings.Join(result.Reasons, ", ")
+		}
+		log.Println(line)
+		report.WriteString(line + "\n")
+		if result.Status != diff.QuickIdentical {
+			flagged.Rules = append(flagged.Rules, rule)
+		}
+	}
+	summary := fmt.Sprintf("identical: %d, suspicious: %d, different: %d", counts[diff.QuickIdentical], counts[diff.QuickSuspicious], counts[diff.QuickDifferent])
+	log.Println("Summary:", summary)
+	report.WriteString(summary + "\n")
+	if err := os.WriteFile(reportFile, []byte(report.String()), 0644); err != nil {
+		log.Println("Error writing report file:", err)
+		return false
+	}
+	data, err := json.MarshalIndent(flagged, "", "  ")
+	if err == nil {
+		err = os.WriteFile(rulesFile, data, 0644)
+	}
+	if err != nil {
+		log.Println("Error writing rules file:", err)
+		return false
+	}
+	log.Printf("Report file: %s, rules of tables to diff: %s\n", reportFile, rulesFile)
+	return ok
+}
+
+func quickTableData(opts *dataDiffOptions, rule pkgconfig.Rule) (*diff.QuickResult, error) {
+	tgtTable, err := opts.tgtDB.ExtractTable(rule.Table)
+	if err != nil {
+		return nil, err
+	}
+	if tgtTable == nil {
+		return nil, fmt.Errorf("table %s not found", rule.Table)
+	}
+	compareRule, err := diff.CreateCompareRuleFromConfig(tgtTable, rule)
+	if err != nil {
+		return nil, err
+	}
+	return diff.QuickCompareData(opts.srcDB, opts.tgtDB, compareRule, rule.StatsColumns)
+}
+
+// rulesFromSchema 未指定规则文件时比对两端都存在且匹配过滤条件的全部表，比对列为除键列外的全部列
+func rulesFromSchema(srcDB, tgtDB conn.DBAdapter, filter *pkgconfig.SchemaFilter) ([]pkgconfig.Rule, error) {
+	// 视图没有可比对的数据
+	filter.Types = []string{string(conn.TableTypeTable)}
+	srcSchema, err := srcDB.ReadSchema(filter)
+	if err != nil {
+		return nil, err
+	}
+	tgtSchema, err := tgtDB.ReadSchema(filter)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range tgtSchema.Tables {
+		if _, ok := srcSchema.Tables[name]; ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	rules := make([]pkgconfig.Rule, 0, len(names))
+	for _, name := range names {
+		tbl := tgtSchema.Tables[name]
+		keys := tbl.GetRowKeyColumns()
+		cols := slices.DeleteFunc(tbl.GetColumnNamesByPosition(), func(c string) bool {
+			return slices.Contains(keys, c)
+		})
+		rules = append(rules, pkgconfig.Rule{Table: name, ComparisonKey: cols})
+	}
+	return rules, nil
+}
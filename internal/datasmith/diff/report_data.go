@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/report"
+)
+
+// reportTablesData 比对各表数据并输出报告，每类差异最多保留 limit 行明细
+func reportTablesData(opts *dataDiffOptions, rules []pkgconfig.Rule, format report.Format, limit int, reportFile string) bool {
+	ok := true
+	tables := make([]*report.TableData, 0, len(rules))
+	for _, rule := range rules {
+		data, err := reportTableData(opts, rule, limit)
+		if err != nil {
+			log.Printf("Error comparing data for table %s: %v\n", rule.Table, err)
+			data.Error = err.Error()
+			ok = false
+		}
+		tables = append(tables, data)
+	}
+	f, err := os.Create(reportFile)
+	if err != nil {
+		log.Println("Error creating report file:", err)
+		return false
+	}
+	defer f.Close()
+	if err := report.WriteData(f, format, tables); err != nil {
+		log.Println("Error writing report file:", err)
+		return false
+	}
+	log.Printf("Report file: %s\n", reportFile)
+	return ok
+}
+
+func reportTableData(opts *dataDiffOptions, rule pkgconfig.Rule, limit int) (*report.TableData, error) {
+	data := report.NewTableData(rule.Table, nil, nil, limit)
+	tgtTable, err := opts.tgtDB.ExtractTable(rule.Table)
+	if err != nil {
+		return data, err
+	}
+	if tgtTable == nil {
+		return data, fmt.Errorf("table %s not found", rule.Table)
+	}
+	compareRule, err := diff.CreateCompareRuleFromConfig(tgtTable, rule)
+	if err != nil {
+		return data, err
+	}
+	data.Columns = tgtTable.GetColumnNamesByPosition()
+	data.KeyColumns = compareRule.GetKeyColumns()
+	if len(data.KeyColumns) == 0 {
+		data.KeyColumns = tgtTable.GetRowKeyColumns()
+	}
+	start := time.Now()
+	log.Printf("Start comparing data for table %s\n", rule.Table)
+	if err := compareTable(opts, compareRule, data); err != nil {
+		return data, err
+	}
+	log.Printf("Table %s time taken: %v, added: %d, dropped: %d, modified: %d\n", rule.Table, time.Since(start), data.Stats.Added, data.Stats.Dropped, data.Stats.Modified)
+	return data, nil
+}
@@ -0,0 +1,29 @@
+package diff
+
+import (
+	pkgconfig "github.com/jacktea/data-smith/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func addSchemaFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("include", nil, "Only compare tables/views matching these glob patterns (re: prefix for regex), added to schemaFilter.include in config")
+	cmd.Flags().StringSlice("exclude", nil, "Skip tables/views matching these glob patterns (re: prefix for regex), added to schemaFilter.exclude in config")
+	cmd.Flags().StringSlice("types", nil, "Object types to compare: table, view (default all)")
+}
+
+// schemaFilter 合并配置文件与命令行中的过滤条件
+func schemaFilter(cmd *cobra.Command, cfg *pkgconfig.Config) (*pkgconfig.SchemaFilter, error) {
+	filter := cfg.SchemaFilter
+	include, _ := cmd.Flags().GetStringSlice("include")
+	exclude, _ := cmd.Flags().GetStringSlice("exclude")
+	filter.Include = append(filter.Include, include...)
+	filter.Exclude = append(filter.Exclude, exclude...)
+	if types, _ := cmd.Flags().GetStringSlice("types"); len(types) > 0 {
+		filter.Types = types
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
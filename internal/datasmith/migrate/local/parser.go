@@ -9,11 +9,23 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/jacktea/data-smith/pkg/logger"
 	"github.com/jacktea/data-smith/pkg/migrate"
 )
 
-// ParseMigrationFile 解析迁移文件名，提取版本号、标题和方向
+var repeatableRe = regexp.MustCompile(`^[rR]__([^.]+)\.sql$`)
+
+// ParseMigrationFile 解析迁移文件名，提取版本号、标题和方向，
+// R__name.sql 形式的文件解析为没有版本号的可重复脚本
 func ParseMigrationFile(path string) (*migrate.MigrationFile, error) {
+	if m := repeatableRe.FindStringSubmatch(filepath.Base(path)); m != nil {
+		return &migrate.MigrationFile{
+			Title:      m[1],
+			Path:       path,
+			Ext:        "sql",
+			Repeatable: true,
+		}, nil
+	}
 	re := regexp.MustCompile(`^([vV]\d+(?:\.\d+)*|\d+)__([^.]+)(?:\.(up|down))?\.(sql|json)$`)
 	matches := re.FindStringSubmatch(filepath.Base(path))
 	if matches == nil {
@@ -42,10 +54,14 @@ func ParseMigrationFile(path string) (*migrate.MigrationFile, error) {
 	}, nil
 }
 
-// ScanMigrations 扫描指定目录下的所有迁移文件
+// ScanMigrations 扫描指定目录下的所有迁移文件，同名脚本出现在多个子目录中、
+// 同一版本同一方向有多个脚本或可重复脚本重名时返回冲突错误
 func ScanMigrations(dir string) ([]*migrate.MigrationFile, error) {
-	fmt.Println("扫描迁移文件目录: ", dir)
+	logger.Infof("扫描迁移文件目录: %s", dir)
 	var files []*migrate.MigrationFile
+	seen := map[string]string{}
+	seenKeys := map[string]string{}
+	var conflicts []string
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -57,6 +73,21 @@ func ScanMigrations(dir string) ([]*migrate.MigrationFile, error) {
 
 		file, err := ParseMigrationFile(path)
 		if err == nil {
+			name := filepath.Base(path)
+			if prev, ok := seen[name]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s 与 %s", prev, path))
+				return nil
+			}
+			seen[name] = path
+			key := migrationKey(file)
+			if prev, ok := seenKeys[key]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s 与 %s", prev, path))
+				return nil
+			}
+			seenKeys[key] = path
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				file.Script = filepath.ToSlash(rel)
+			}
 			files = append(files, file)
 		}
 		return nil
@@ -65,12 +96,55 @@ func ScanMigrations(dir string) ([]*migrate.MigrationFile, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("迁移脚本冲突: %s", strings.Join(conflicts, "; "))
+	}
 
 	return files, nil
 }
 
+// migrationKey 脚本的唯一标识：可重复脚本为名称，版本脚本为版本号与方向，
+// 版本号忽略 V 前缀与末尾的 .0(与 CompareVersion 一致)，未标注方向视为 up
+func migrationKey(f *migrate.MigrationFile) string {
+	if f.Repeatable {
+		return "R__" + f.Title
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.ToLower(f.Version), "v"), ".")
+	for i, p := range parts {
+		if n, err := strconv.Atoi(p); err == nil {
+			parts[i] = strconv.Itoa(n)
+		}
+	}
+	for len(parts) > 1 && parts[len(parts)-1] == "0" {
+		parts = parts[:len(parts)-1]
+	}
+	direction := f.Direction
+	if direction == "" {
+		direction = "up"
+	}
+	return strings.Join(parts, ".") + "/" + direction
+}
+
+// UpMigrations 返回向上迁移的脚本，未标注方向的脚本视为 up
+func UpMigrations(files []*migrate.MigrationFile) []*migrate.MigrationFile {
+	var ups []*migrate.MigrationFile
+	for _, f := range files {
+		if f.Direction != "down" {
+			ups = append(ups, f)
+		}
+	}
+	return ups
+}
+
+// SortMigrations 按版本排序，可重复脚本按名称排在所有版本之后
 func SortMigrations(files []*migrate.MigrationFile) {
 	sort.Slice(files, func(i, j int) bool {
+		if files[i].Repeatable != files[j].Repeatable {
+			return files[j].Repeatable
+		}
+		if files[i].Repeatable {
+			return files[i].Title < files[j].Title
+		}
 		if files[i].Version == files[j].Version {
 			return files[i].Direction == "down" && files[j].Direction == "up"
 		}
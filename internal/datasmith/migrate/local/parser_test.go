@@ -0,0 +1,69 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeScripts(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanMigrations(t *testing.T) {
+	dir := t.TempDir()
+	writeScripts(t, dir, "V1.0.0.110__feature.sql", "hotfix/V1.0.0.105__fix.sql", "R__views.sql", "README.md")
+	files, err := ScanMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortMigrations(files)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if strings.Join(names, ",") != "V1.0.0.105__fix,V1.0.0.110__feature,R__views" {
+		t.Errorf("unexpected order %v", names)
+	}
+	if files[0].Script != "hotfix/V1.0.0.105__fix.sql" {
+		t.Errorf("script path should be relative to the directory, got %s", files[0].Script)
+	}
+
+	writeScripts(t, dir, "release/V1.0.0.110__feature.sql")
+	if _, err := ScanMigrations(dir); err == nil || !strings.Contains(err.Error(), "冲突") {
+		t.Errorf("expect conflict error, got %v", err)
+	}
+}
+
+func TestScanMigrationsDuplicateVersion(t *testing.T) {
+	tests := [][]string{
+		{"V2__a.sql", "V2__b.sql"},
+		{"V2__a.sql", "v2.0__b.up.sql"},
+		{"V2__a.down.sql", "V2__b.down.sql"},
+		{"R__views.sql", "sub/r__views.sql"},
+	}
+	for _, names := range tests {
+		dir := t.TempDir()
+		writeScripts(t, dir, names...)
+		if _, err := ScanMigrations(dir); err == nil || !strings.Contains(err.Error(), "冲突") {
+			t.Errorf("%v: expect conflict error, got %v", names, err)
+		}
+	}
+
+	// 同一版本的 up 与 down 脚本不冲突
+	dir := t.TempDir()
+	writeScripts(t, dir, "V2__a.sql", "V2__a.down.sql")
+	if _, err := ScanMigrations(dir); err != nil {
+		t.Errorf("up and down of the same version should not conflict: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package migrate
+
+import (
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().String("lock-mode", "", "Migration lock: advisory, table (default from config, then advisory)")
+	cmd.Flags().Duration("lock-timeout", 0, "How long to wait for the migration lock (default from config, then 5m)")
+	cmd.Flags().Bool("force-unlock", false, "Remove a stale record in schema_migrations_lock left by a crashed process before acquiring the lock")
+}
+
+// withMigrationLock 持有迁移锁执行 fn，命令行参数优先于配置文件
+func withMigrationLock(cmd *cobra.Command, cfg *config.Config, db conn.DBAdapter, fn func() error) error {
+	mode, timeout := cfg.Migration.LockMode, cfg.Migration.LockTimeout
+	if cmd.Flags().Changed("lock-mode") {
+		mode, _ = cmd.Flags().GetString("lock-mode")
+	}
+	if cmd.Flags().Changed("lock-timeout") {
+		timeout, _ = cmd.Flags().GetDuration("lock-timeout")
+	}
+	if force, _ := cmd.Flags().GetBool("force-unlock"); force {
+		holder, lockedAt, err := migrate.ForceUnlock(db)
+		if err != nil {
+			return err
+		}
+		if holder != "" {
+			logger.Warnf("已删除 %s 于 %s 持有的迁移锁", holder, lockedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+	logger.Info("获取迁移锁")
+	lock, err := migrate.AcquireLock(db, mode, timeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Warnf("释放迁移锁失败: %v", err)
+		}
+	}()
+	return fn()
+}
@@ -5,4 +5,8 @@ import "github.com/spf13/cobra"
 func Install(root *cobra.Command) {
 	root.AddCommand(resetDBCmd)
 	root.AddCommand(migrateScript)
+	root.AddCommand(migrateValidate)
+	root.AddCommand(migrateRollback)
+	root.AddCommand(migrateStatus)
+	root.AddCommand(migrateRepair)
 }
@@ -1,6 +1,7 @@
 package migrate
 
 import (
+	"errors"
 	"os"
 
 	"github.com/jacktea/data-smith/internal/config"
@@ -31,11 +32,21 @@ var migrateScript = &cobra.Command{
 		}
 		defer tgtDB.Close()
 
-		dryRun, _ := cmd.Flags().GetBool("dry-run")
-		dir, _ := cmd.Flags().GetString("dir")
-		targetVersion, _ := cmd.Flags().GetString("version")
+		opts := &migrateOptions{}
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		opts.Dir, _ = cmd.Flags().GetString("dir")
+		opts.TargetVersion, _ = cmd.Flags().GetString("version")
+		opts.Repair, _ = cmd.Flags().GetBool("repair")
+		opts.OutOfOrder, _ = cmd.Flags().GetBool("out-of-order")
+		opts.Placeholders, err = placeholders(cmd, cfg)
+		if err != nil {
+			logger.Errorf("Error parsing placeholders: %v", err)
+			os.Exit(1)
+		}
 
-		err = runMigrations(tgtDB, dir, dryRun, targetVersion)
+		err = withMigrationLock(cmd, cfg, tgtDB, func() error {
+			return runMigrations(tgtDB, opts)
+		})
 		if err != nil {
 			logger.Errorf("Error running migrations: %v", err)
 			os.Exit(1)
@@ -49,45 +60,114 @@ func init() {
 	migrateScript.Flags().StringP("dir", "d", "", "Path to migration script directory")
 	migrateScript.Flags().StringP("version", "v", "", "Target version")
 	migrateScript.Flags().BoolP("dry-run", "n", false, "Dry run")
+	migrateScript.Flags().Bool("repair", false, "Repair migration history before running when validation fails")
+	migrateScript.Flags().Bool("out-of-order", false, "Apply unapplied scripts whose version is lower than the current version")
+	addLockFlags(migrateScript)
+	addPlaceholderFlags(migrateScript)
 	migrateScript.MarkFlagRequired("config")
 	migrateScript.MarkFlagRequired("dir")
 }
 
-func runMigrations(db conn.DBAdapter, dir string, dryRun bool, targetVersion string) error {
-	logger.Infof("开始执行迁移, 脚本目录: %s", dir)
-	files, err := local.ScanMigrations(dir)
+// migrateOptions migrate-script 的参数
+type migrateOptions struct {
+	Dir           string
+	DryRun        bool
+	TargetVersion string
+	Repair        bool
+	OutOfOrder    bool
+	Placeholders  *migrate.Placeholders
+}
+
+func runMigrations(db conn.DBAdapter, opts *migrateOptions) error {
+	logger.Infof("开始执行迁移, 脚本目录: %s", opts.Dir)
+	files, err := local.ScanMigrations(opts.Dir)
 	if err != nil {
 		return err
 	}
+	for _, f := range files {
+		f.Placeholders = opts.Placeholders
+	}
 	local.SortMigrations(files)
+	files = local.UpMigrations(files)
 
 	logger.Info("创建或更新配置表")
 	err = migrate.EnsureVersionTable(db)
 	if err != nil {
 		return err
 	}
+	logger.Info("校验已执行的迁移脚本")
+	result, err := migrate.ValidateMigrations(db, files)
+	if err != nil {
+		return err
+	}
+	for _, issue := range result.Issues {
+		logger.Warnf("[%s] %s", issue.Kind, issue)
+	}
+	// 缺少校验和的旧记录总是补齐，其余问题只在 --repair 时修复
+	issues := result.Filter(migrate.IssueUnverified)
+	if result.Failed() {
+		if !opts.Repair {
+			return errors.New("迁移脚本校验失败，请确认后使用 --repair 修复迁移历史")
+		}
+		issues = result.Issues
+	}
+	if !opts.DryRun {
+		if err := migrate.RepairMigrations(db, issues); err != nil {
+			return err
+		}
+	}
 	currentVersion, err := migrate.CurrentVersion(db)
 	if err != nil {
 		return err
 	}
 	logger.Infof("获取当前版本: %s", currentVersion)
-	if targetVersion == "" && len(files) > 0 {
-		targetVersion = files[len(files)-1].Version
+	var versioned []*migrate.MigrationFile
+	for _, f := range files {
+		if !f.Repeatable {
+			versioned = append(versioned, f)
+		}
+	}
+	targetVersion := opts.TargetVersion
+	if targetVersion == "" && len(versioned) > 0 {
+		targetVersion = versioned[len(versioned)-1].Version
+	}
+	applied, err := migrate.AppliedVersions(db)
+	if err != nil {
+		return err
+	}
+	// 按已执行的版本集合选择脚本，低于当前版本的未执行脚本只在指定 --out-of-order 时执行
+	var pendingFiles, skipped []*migrate.MigrationFile
+	for _, f := range versioned {
+		if applied[f.Version] || local.CompareVersion(f.Version, targetVersion) > 0 {
+			continue
+		}
+		if local.CompareVersion(f.Version, currentVersion) < 0 && !opts.OutOfOrder {
+			skipped = append(skipped, f)
+			continue
+		}
+		pendingFiles = append(pendingFiles, f)
 	}
-	if targetVersion != "" && local.CompareVersion(currentVersion, targetVersion) >= 0 {
+	for _, f := range skipped {
+		logger.Warnf("脚本 %s 的版本低于当前版本 %s 且未执行，已跳过，可使用 --out-of-order 执行", f.ScriptName(), currentVersion)
+	}
+	// 可重复脚本在所有版本之后执行
+	repeatables, err := migrate.PendingRepeatables(db, files)
+	if err != nil {
+		return err
+	}
+	if len(pendingFiles) == 0 && len(repeatables) == 0 {
 		logger.Infof("当前版本: %s, 目标版本: %s, 无需执行迁移", currentVersion, targetVersion)
 		return nil
 	}
-	var pendingFiles []*migrate.MigrationFile
-	for _, f := range files {
-		if local.CompareVersion(f.Version, currentVersion) > 0 {
-			if targetVersion == "" || local.CompareVersion(f.Version, targetVersion) <= 0 {
-				pendingFiles = append(pendingFiles, f)
-			}
+	logger.Infof("获取待执行的迁移文件: %d, 可重复脚本: %d", len(pendingFiles), len(repeatables))
+	pendingFiles = append(pendingFiles, repeatables...)
+	// 执行前检查全部脚本，避免执行到一半才发现未定义的占位符或格式错误
+	for _, f := range pendingFiles {
+		if err := f.Check(); err != nil {
+			return err
 		}
 	}
-	logger.Infof("获取待执行的迁移文件: %d", len(pendingFiles))
-	if dryRun {
+	if opts.DryRun {
 		logger.Info("开始执行迁移(预览模式)")
 		err = migrate.DryRunMigrations(db, pendingFiles)
 	} else {
@@ -0,0 +1,21 @@
+package migrate
+
+import (
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+func addPlaceholderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("set", nil, "Set a script placeholder, e.g. --set owner=app (repeatable)")
+}
+
+// placeholders 由配置文件与 --set 参数创建脚本占位符
+func placeholders(cmd *cobra.Command, cfg *config.Config) (*migrate.Placeholders, error) {
+	pairs, _ := cmd.Flags().GetStringArray("set")
+	overrides, err := migrate.ParsePlaceholders(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewPlaceholders(&cfg.TargetDB, cfg.Migration.Placeholders, overrides), nil
+}
@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"os"
+
+	"github.com/jacktea/data-smith/internal/config"
+	"github.com/jacktea/data-smith/pkg/db"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateRepair = &cobra.Command{
+	Use:   "migrate-repair",
+	Short: "Remove failed migration records",
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Errorf("Error loading config: %v", err)
+			os.Exit(1)
+		}
+
+		tgtDB, err := db.NewDBAdapter(&cfg.TargetDB)
+		if err != nil {
+			logger.Errorf("Error connecting to target DB: %v", err)
+			os.Exit(1)
+		}
+		defer tgtDB.Close()
+
+		if err := migrate.EnsureVersionTable(tgtDB); err != nil {
+			logger.Errorf("Error creating version table: %v", err)
+			os.Exit(1)
+		}
+		n, err := migrate.ClearFailedMigrations(tgtDB)
+		if err != nil {
+			logger.Errorf("Error repairing migrations: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("已删除 %d 条执行失败的记录", n)
+	},
+}
+
+func init() {
+	migrateRepair.Flags().StringP("config", "c", "", "Path to config file")
+	migrateRepair.MarkFlagRequired("config")
+}
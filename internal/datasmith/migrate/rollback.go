@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"os"
+
+	"github.com/jacktea/data-smith/internal/config"
+	"github.com/jacktea/data-smith/internal/datasmith/migrate/local"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/db"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateRollback = &cobra.Command{
+	Use:   "migrate-rollback",
+	Short: "Roll back applied migrations with down scripts",
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+		dir, _ := cmd.Flags().GetString("dir")
+		to, _ := cmd.Flags().GetString("to")
+		steps, _ := cmd.Flags().GetInt("steps")
+		if to == "" && steps <= 0 {
+			logger.Errorf("Either --to or --steps is required")
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Errorf("Error loading config: %v", err)
+			os.Exit(1)
+		}
+
+		tgtDB, err := db.NewDBAdapter(&cfg.TargetDB)
+		if err != nil {
+			logger.Errorf("Error connecting to target DB: %v", err)
+			os.Exit(1)
+		}
+		defer tgtDB.Close()
+
+		ph, err := placeholders(cmd, cfg)
+		if err != nil {
+			logger.Errorf("Error parsing placeholders: %v", err)
+			os.Exit(1)
+		}
+
+		err = withMigrationLock(cmd, cfg, tgtDB, func() error {
+			return runRollback(tgtDB, dir, to, steps, ph)
+		})
+		if err != nil {
+			logger.Errorf("Error rolling back migrations: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateRollback.Flags().StringP("config", "c", "", "Path to config file")
+	migrateRollback.Flags().StringP("dir", "d", "", "Path to migration script directory")
+	migrateRollback.Flags().String("to", "", "Roll back all versions above this version")
+	migrateRollback.Flags().Int("steps", 0, "Number of versions to roll back")
+	migrateRollback.MarkFlagsMutuallyExclusive("to", "steps")
+	addLockFlags(migrateRollback)
+	addPlaceholderFlags(migrateRollback)
+	migrateRollback.MarkFlagRequired("config")
+	migrateRollback.MarkFlagRequired("dir")
+}
+
+func runRollback(db conn.DBAdapter, dir, to string, steps int, ph *migrate.Placeholders) error {
+	logger.Infof("开始回滚迁移, 脚本目录: %s", dir)
+	files, err := local.ScanMigrations(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		f.Placeholders = ph
+	}
+	if err := migrate.EnsureVersionTable(db); err != nil {
+		return err
+	}
+	records, err := migrate.AppliedRecords(db)
+	if err != nil {
+		return err
+	}
+	plan, err := migrate.PlanRollback(records, files, to, steps)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		logger.Info("没有需要回滚的版本")
+		return nil
+	}
+	logger.Infof("待回滚的版本: %d", len(plan))
+	for _, step := range plan {
+		if err := step.File.Check(); err != nil {
+			return err
+		}
+	}
+	return migrate.RollbackMigrations(db, plan)
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jacktea/data-smith/internal/config"
+	"github.com/jacktea/data-smith/internal/datasmith/migrate/local"
+	"github.com/jacktea/data-smith/pkg/db"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateStatus = &cobra.Command{
+	Use:   "migrate-status",
+	Short: "Show the state of every migration script",
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+		dir, _ := cmd.Flags().GetString("dir")
+		format, _ := cmd.Flags().GetString("format")
+		if format != "table" && format != "json" {
+			logger.Errorf("Unsupported format: %s", format)
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Errorf("Error loading config: %v", err)
+			os.Exit(1)
+		}
+
+		tgtDB, err := db.NewDBAdapter(&cfg.TargetDB)
+		if err != nil {
+			logger.Errorf("Error connecting to target DB: %v", err)
+			os.Exit(1)
+		}
+		defer tgtDB.Close()
+
+		files, err := local.ScanMigrations(dir)
+		if err != nil {
+			logger.Errorf("Error scanning migrations: %v", err)
+			os.Exit(1)
+		}
+		local.SortMigrations(files)
+		if err := migrate.EnsureVersionTable(tgtDB); err != nil {
+			logger.Errorf("Error creating version table: %v", err)
+			os.Exit(1)
+		}
+		statuses, err := migrate.MigrationStatuses(tgtDB, files)
+		if err != nil {
+			logger.Errorf("Error reading migration status: %v", err)
+			os.Exit(1)
+		}
+		if format == "json" {
+			err = writeStatusJSON(os.Stdout, statuses)
+		} else {
+			err = writeStatusTable(os.Stdout, statuses)
+		}
+		if err != nil {
+			logger.Errorf("Error writing migration status: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateStatus.Flags().StringP("config", "c", "", "Path to config file")
+	migrateStatus.Flags().StringP("dir", "d", "", "Path to migration script directory")
+	migrateStatus.Flags().StringP("format", "f", "table", "Output format: table, json")
+	migrateStatus.MarkFlagRequired("config")
+	migrateStatus.MarkFlagRequired("dir")
+}
+
+func writeStatusTable(w io.Writer, statuses []*migrate.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tTITLE\tSTATE\tAPPLIED_AT\tEXECUTION_TIME")
+	for _, s := range statuses {
+		appliedAt, execTime := "", ""
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			execTime = fmt.Sprintf("%dms", s.ExecutionTime)
+		}
+		version := s.Version
+		if s.Repeatable {
+			version = "R"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", version, s.Title, s.State, appliedAt, execTime)
+	}
+	return tw.Flush()
+}
+
+func writeStatusJSON(w io.Writer, statuses []*migrate.MigrationStatus) error {
+	if statuses == nil {
+		statuses = []*migrate.MigrationStatus{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jacktea/data-smith/internal/config"
+	"github.com/jacktea/data-smith/internal/datasmith/migrate/local"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/db"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateValidate = &cobra.Command{
+	Use:   "migrate-validate",
+	Short: "Validate applied migrations against local scripts",
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Errorf("Error loading config: %v", err)
+			os.Exit(1)
+		}
+
+		tgtDB, err := db.NewDBAdapter(&cfg.TargetDB)
+		if err != nil {
+			logger.Errorf("Error connecting to target DB: %v", err)
+			os.Exit(1)
+		}
+		defer tgtDB.Close()
+
+		dir, _ := cmd.Flags().GetString("dir")
+		repair, _ := cmd.Flags().GetBool("repair")
+
+		result, err := validateMigrations(tgtDB, dir)
+		if err != nil {
+			logger.Errorf("Error validating migrations: %v", err)
+			os.Exit(1)
+		}
+		for _, issue := range result.Issues {
+			fmt.Printf("[%s] %s\n", issue.Kind, issue)
+		}
+		if repair {
+			if err := migrate.RepairMigrations(tgtDB, result.Issues); err != nil {
+				logger.Errorf("Error repairing migrations: %v", err)
+				os.Exit(1)
+			}
+			fmt.Println("迁移历史已修复")
+			return
+		}
+		if result.Failed() {
+			os.Exit(1)
+		}
+		fmt.Println("校验通过")
+	},
+}
+
+func init() {
+	migrateValidate.Flags().StringP("config", "c", "", "Path to config file")
+	migrateValidate.Flags().StringP("dir", "d", "", "Path to migration script directory")
+	migrateValidate.Flags().Bool("repair", false, "Realign checksums of modified scripts and mark missing scripts as deleted")
+	migrateValidate.MarkFlagRequired("config")
+	migrateValidate.MarkFlagRequired("dir")
+}
+
+// validateMigrations 扫描脚本目录并与迁移历史比对
+func validateMigrations(db conn.DBAdapter, dir string) (*migrate.ValidationResult, error) {
+	files, err := local.ScanMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	local.SortMigrations(files)
+	if err := migrate.EnsureVersionTable(db); err != nil {
+		return nil, err
+	}
+	return migrate.ValidateMigrations(db, files)
+}
@@ -1,6 +1,7 @@
 package datasmith
 
 import (
+	"errors"
 	"log"
 	"os"
 
@@ -21,6 +22,13 @@ func init() {
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *diff.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.Err != nil {
+				log.Printf("Error: %v\n", exitErr.Err)
+			}
+			os.Exit(exitErr.Code)
+		}
 		log.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
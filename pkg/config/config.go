@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jacktea/data-smith/pkg/consts"
 )
@@ -21,6 +25,9 @@ type ConnConfig struct {
 	SSL         bool          `yaml:"ssl"`
 	Extra       DBParams      `yaml:"extra"`
 	Proxy       any           `yaml:"proxy"`
+	// 连接池限制，0 表示使用驱动默认值(不限制)
+	MaxOpenConns int `yaml:"maxOpenConns"`
+	MaxIdleConns int `yaml:"maxIdleConns"`
 }
 
 func (c *ConnConfig) ExtraString() string {
@@ -58,12 +65,113 @@ func (c *ConnConfig) RemoveExtra(key string) {
 type Config struct {
 	SourceDB ConnConfig `yaml:"sourceDb"`
 	TargetDB ConnConfig `yaml:"targetDb"`
+	// SchemaFilter 结构读取与比对的过滤条件
+	SchemaFilter SchemaFilter `yaml:"schemaFilter"`
+	// Migration 脚本迁移的选项
+	Migration MigrationConfig `yaml:"migration"`
+}
+
+// MigrationConfig 脚本迁移的选项
+type MigrationConfig struct {
+	// LockMode 迁移锁的实现: advisory(数据库咨询锁，默认)/table(锁表)
+	LockMode string `yaml:"lockMode"`
+	// LockTimeout 等待迁移锁的时间，如 "5m"，0 表示使用默认值
+	LockTimeout time.Duration `yaml:"lockTimeout"`
+	// Placeholders 脚本中 ${name} 占位符的取值
+	Placeholders map[string]string `yaml:"placeholders"`
+}
+
+// SchemaFilter 按对象类型与名称过滤表和视图，并可忽略部分差异
+// 名称模式默认为 glob(如 "app_*")，以 "re:" 开头时为正则表达式(如 "re:^tmp_\\d+$")
+type SchemaFilter struct {
+	// Include 只处理匹配的对象，为空时处理全部
+	Include []string `yaml:"include"`
+	// Exclude 排除匹配的对象，优先于 Include
+	Exclude []string `yaml:"exclude"`
+	// Types 处理的对象类型: table/view，为空时处理全部
+	Types []string `yaml:"types"`
+	// IgnoreComments 不比较列注释
+	IgnoreComments bool `yaml:"ignoreComments"`
+	// IgnoreIndexNames 按定义而不是名称匹配索引，同时不比较主键约束名
+	IgnoreIndexNames bool `yaml:"ignoreIndexNames"`
+	// IgnoreDefaults 不比较列默认值
+	IgnoreDefaults bool `yaml:"ignoreDefaults"`
+}
+
+// Validate 检查正则表达式是否合法
+func (f *SchemaFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, p := range append(slices.Clone(f.Include), f.Exclude...) {
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		} else if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Match 对象是否需要处理，typ 为 table 或 view(不区分大小写)，filter 为 nil 时处理全部
+func (f *SchemaFilter) Match(name, typ string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !slices.ContainsFunc(f.Types, func(t string) bool { return strings.EqualFold(t, typ) }) {
+		return false
+	}
+	if len(f.Include) > 0 && !matchPatterns(f.Include, name) {
+		return false
+	}
+	return !matchPatterns(f.Exclude, name)
+}
+
+func matchPatterns(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			if matched, _ := regexp.MatchString(expr, name); matched {
+				return true
+			}
+		} else if matched, _ := path.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
 }
 
 // Rule defines a single comparison rule.
 type Rule struct {
 	Table         string   `json:"table"`
 	ComparisonKey []string `json:"comparisonKey"`
+	// Where 行过滤条件，如 "tenant_id = 42"，两端使用同一条件
+	Where string `json:"where,omitempty"`
+	// Query 自定义查询，结果集作为子查询参与比对，需包含主键或 KeyColumns 指定的列
+	Query string `json:"query,omitempty"`
+	// KeyColumns 比对与分页使用的键列，为空时使用表主键
+	KeyColumns []string `json:"keyColumns,omitempty"`
+	// IgnoreColumns 不参与比对的列，如 updated_at、version
+	IgnoreColumns []string `json:"ignoreColumns,omitempty"`
+	// Columns 按列指定比较方式，key 为列名
+	Columns map[string]ColumnRule `json:"columns,omitempty"`
+	// StatsColumns 快速比对时统计空值数、不同值数与合计(数值列)的列
+	StatsColumns []string `json:"statsColumns,omitempty"`
+}
+
+// ColumnRule 单列的比较方式
+type ColumnRule struct {
+	// Compare 比较方式: numeric/timestamp/text/json/exact，为空时按列类型选择
+	Compare string `json:"compare,omitempty"`
+	// Tolerance 数值比较允许的绝对误差
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Precision 时间比较精度: s/ms/us，比较前按该精度截断
+	Precision string `json:"precision,omitempty"`
+	// IgnoreCase 文本比较忽略大小写
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+	// IgnoreWhitespace 文本比较忽略首尾空白，并将连续空白视为一个空格
+	IgnoreWhitespace bool `json:"ignoreWhitespace,omitempty"`
 }
 
 // RuleSet defines a set of comparison rules.
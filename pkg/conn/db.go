@@ -2,6 +2,7 @@ package conn
 
 import (
 	"database/sql"
+	"slices"
 	"sort"
 
 	"github.com/jacktea/data-smith/pkg/config"
@@ -74,6 +75,16 @@ func (t *Table) GetColumnsByPosition() []*Column {
 	return cols
 }
 
+// GetColumnNamesByPosition 按列位置返回列名
+func (t *Table) GetColumnNamesByPosition() []string {
+	cols := t.GetColumnsByPosition()
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	return names
+}
+
 func (t *Table) GetPrimaryKeyColumns() []string {
 	if t.PrimaryKey == nil {
 		return nil
@@ -81,6 +92,63 @@ func (t *Table) GetPrimaryKeyColumns() []string {
 	return t.PrimaryKey.Columns
 }
 
+// GetRowKeyColumns 返回可唯一定位一行的列：优先使用主键，
+// 其次使用列均非空的唯一索引(列数最少者)，都没有时返回 nil
+func (t *Table) GetRowKeyColumns() []string {
+	if pks := t.GetPrimaryKeyColumns(); len(pks) > 0 {
+		return pks
+	}
+	var best *Index
+	for _, idx := range t.Indexes {
+		if !idx.Unique || idx.Where != nil || idx.Expression != nil || len(idx.Columns) == 0 {
+			continue
+		}
+		usable := true
+		for _, c := range idx.Columns {
+			if col := t.GetColumn(c); col == nil || col.Nullable {
+				usable = false
+				break
+			}
+		}
+		if !usable {
+			continue
+		}
+		if best == nil || len(idx.Columns) < len(best.Columns) ||
+			(len(idx.Columns) == len(best.Columns) && idx.Name < best.Name) {
+			best = idx
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.Columns
+}
+
+// IsUniqueKey 判断 cols 是否恰好为主键或某个唯一索引的列(不计顺序)，
+// 部分索引与表达式索引不能作为 ON CONFLICT 的冲突目标，不计入
+func (t *Table) IsUniqueKey(cols []string) bool {
+	sameColumns := func(a []string) bool {
+		if len(a) != len(cols) {
+			return false
+		}
+		for _, c := range a {
+			if !slices.Contains(cols, c) {
+				return false
+			}
+		}
+		return true
+	}
+	if pks := t.GetPrimaryKeyColumns(); len(pks) > 0 && sameColumns(pks) {
+		return true
+	}
+	for _, idx := range t.Indexes {
+		if idx.Unique && idx.Where == nil && idx.Expression == nil && sameColumns(idx.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Table) GetIndex(name string) *Index {
 	return t.Indexes[name]
 }
@@ -148,9 +216,63 @@ type ViewDefinition struct {
 
 type Record map[string]any
 
+// DataFilter 数据比对的行范围限定
+type DataFilter struct {
+	// Where 过滤条件，与分页条件以 AND 组合
+	Where string
+	// Query 自定义查询，结果集作为子查询参与分页
+	Query string
+}
+
+// IsEmpty 是否未设置任何过滤
+func (f *DataFilter) IsEmpty() bool {
+	return f == nil || (f.Where == "" && f.Query == "")
+}
+
+// Checksum 主键区间内数据的行数与校验和
+type Checksum struct {
+	Count int64
+	Sum   string
+}
+
+func (c *Checksum) Equal(o *Checksum) bool {
+	if c == nil || o == nil {
+		return c == o
+	}
+	return c.Count == o.Count && c.Sum == o.Sum
+}
+
+// TableStats 表数据的统计信息，用于快速比对
+type TableStats struct {
+	RowCount int64
+	// MinKey/MaxKey 键列的最小值与最大值，未指定键列或表为空时为 nil
+	MinKey any
+	MaxKey any
+	// Columns 指定列的聚合统计
+	Columns map[string]*ColumnStats
+}
+
+// ColumnStats 单列的聚合统计
+type ColumnStats struct {
+	NullCount int64
+	Distinct  int64
+	// Sum 数值列的合计，非数值列为 nil
+	Sum any
+}
+
 type DBAdapter interface {
-	ReadSchema() (*DatabaseSchema, error)
-	GetTableDataBatch(table string, cols, pk []string, lastPK []any, limit int) ([]Record, error)
+	// ReadSchema 读取表和视图的结构，filter 不为 nil 时只读取匹配的对象
+	ReadSchema(filter *config.SchemaFilter) (*DatabaseSchema, error)
+	// GetTableDataBatch 按主键分页读取数据，filter 为 nil 时读取整表
+	GetTableDataBatch(table *Table, filter *DataFilter, cols, pk []string, lastPK []any, limit int) ([]Record, error)
+	// GetTableDataPage 按全部列排序后分页读取数据，用于没有主键与唯一索引的表
+	GetTableDataPage(table *Table, filter *DataFilter, cols []string, offset, limit int) ([]Record, error)
+	// GetPKRange 获取单列主键的最小值与最大值，表为空时返回 nil
+	GetPKRange(table *Table, filter *DataFilter, pk string) (min, max any, err error)
+	// GetRangeChecksum 在数据库端计算主键区间 [lower, upper) 内数据的行数与校验和
+	GetRangeChecksum(table *Table, filter *DataFilter, cols []string, pk string, lower, upper any) (*Checksum, error)
+	// GetTableStats 统计行数、键列的最小/最大值以及 cols 的空值数、不同值数与数值列合计
+	GetTableStats(table *Table, filter *DataFilter, key string, cols []string) (*TableStats, error)
 	ExtractTable(tableName string) (*Table, error)
 	ExtractView(viewName string) (*Table, error)
 	GetConn() *sql.DB
@@ -0,0 +1,32 @@
+package conn
+
+import "testing"
+
+func TestIsUniqueKey(t *testing.T) {
+	where := "deleted = false"
+	tbl := &Table{
+		Name:       "t",
+		PrimaryKey: &PrimaryKey{Columns: []string{"id"}},
+		Indexes: map[string]*Index{
+			"uk_code":   {Name: "uk_code", Columns: []string{"tenant", "code"}, Unique: true},
+			"idx_name":  {Name: "idx_name", Columns: []string{"name"}},
+			"uk_active": {Name: "uk_active", Columns: []string{"email"}, Unique: true, Where: &where},
+		},
+	}
+	tests := []struct {
+		cols   []string
+		expect bool
+	}{
+		{[]string{"id"}, true},
+		{[]string{"code", "tenant"}, true},
+		{[]string{"code"}, false},
+		{[]string{"name"}, false},
+		{[]string{"email"}, false},
+		{[]string{"id", "code"}, false},
+	}
+	for _, tt := range tests {
+		if got := tbl.IsUniqueKey(tt.cols); got != tt.expect {
+			t.Errorf("IsUniqueKey(%v) = %v, expect %v", tt.cols, got, tt.expect)
+		}
+	}
+}
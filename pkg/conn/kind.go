@@ -0,0 +1,93 @@
+package conn
+
+import "strings"
+
+// ColumnKind 列值的类别，用于跨数据库的值比较
+type ColumnKind int
+
+const (
+	ColumnKindUnknown ColumnKind = iota
+	ColumnKindNumeric
+	ColumnKindText
+	ColumnKindTime
+	ColumnKindBinary
+	ColumnKindUUID
+	ColumnKindBool
+	ColumnKindJSON
+)
+
+var integerTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "mediumint": true, "int": true, "integer": true, "bigint": true,
+	"int2": true, "int4": true, "int8": true, "serial": true, "smallserial": true, "bigserial": true,
+}
+
+var numericTypes = map[string]bool{
+	"numeric": true, "decimal": true, "real": true, "money": true,
+}
+
+// ParseColumnKind 根据 information_schema 中的 data_type 解析列的类别
+// 同时兼容 MySQL 与 PostgreSQL 的类型名称
+func ParseColumnKind(dataType string) ColumnKind {
+	dt := strings.ToLower(strings.TrimSpace(dataType))
+	switch {
+	case dt == "":
+		return ColumnKindUnknown
+	case dt == "uuid":
+		return ColumnKindUUID
+	case strings.HasPrefix(dt, "json"):
+		return ColumnKindJSON
+	case dt == "boolean" || dt == "bool":
+		return ColumnKindBool
+	case integerTypes[dt] || numericTypes[dt] || strings.HasPrefix(dt, "float") || strings.HasPrefix(dt, "double"):
+		return ColumnKindNumeric
+	case strings.Contains(dt, "char") || strings.Contains(dt, "text") ||
+		strings.HasPrefix(dt, "enum") || strings.HasPrefix(dt, "set") || dt == "citext":
+		return ColumnKindText
+	case strings.HasPrefix(dt, "time") || strings.HasPrefix(dt, "date") || dt == "year":
+		return ColumnKindTime
+	case strings.Contains(dt, "binary") || strings.Contains(dt, "blob") || dt == "bytea":
+		return ColumnKindBinary
+	default:
+		return ColumnKindUnknown
+	}
+}
+
+// Kind 返回列的类别
+func (c *Column) Kind() ColumnKind {
+	if c == nil {
+		return ColumnKindUnknown
+	}
+	return ParseColumnKind(c.DataType)
+}
+
+// IsText 判断列是否为文本类型，文本类型的排序受数据库排序规则影响
+func (c *Column) IsText() bool {
+	return c.Kind() == ColumnKindText
+}
+
+// IsInteger 判断列是否为整数类型
+func (c *Column) IsInteger() bool {
+	if c == nil {
+		return false
+	}
+	return integerTypes[strings.ToLower(strings.TrimSpace(c.DataType))]
+}
+
+// IsFloat 判断列是否为近似数值的浮点类型(float、double、real)，不含精确的 DECIMAL/NUMERIC
+func (c *Column) IsFloat() bool {
+	if c == nil {
+		return false
+	}
+	dt := strings.ToLower(strings.TrimSpace(c.DataType))
+	return strings.HasPrefix(dt, "float") || strings.HasPrefix(dt, "double") || dt == "real"
+}
+
+// HasTimeZone 判断时间列是否表示时间点：PostgreSQL 的 with time zone 类型与 MySQL 的 timestamp，
+// 其余时间类型(datetime、timestamp without time zone、date 等)只保存墙上时间
+func (c *Column) HasTimeZone() bool {
+	if c == nil {
+		return false
+	}
+	dt := strings.ToLower(strings.TrimSpace(c.DataType))
+	return strings.HasSuffix(dt, " with time zone") || dt == "timestamptz" || dt == "timetz" || dt == "timestamp"
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
 	"github.com/jacktea/data-smith/pkg/proxy"
 )
 
@@ -26,6 +27,46 @@ func (p *BaseAdapter) Close() error {
 	return nil
 }
 
+// ConfigurePool 按配置设置连接池限制
+func (p *BaseAdapter) ConfigurePool(db *sql.DB) {
+	if p.Cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.Cfg.MaxOpenConns)
+	}
+	if p.Cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.Cfg.MaxIdleConns)
+	}
+}
+
+// QueryRecords 执行查询并按结果集的列名生成记录
+func (p *BaseAdapter) QueryRecords(query string, args ...any) ([]conn.Record, error) {
+	rows, err := p.Conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []conn.Record
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		rec := conn.Record{}
+		for i, c := range cols {
+			rec[c] = vals[i]
+		}
+		result = append(result, rec)
+	}
+	return result, rows.Err()
+}
+
 func (p *BaseAdapter) Init(cfg *config.ConnConfig) error {
 	p.Cfg = cfg
 	if cfg.Proxy == nil {
@@ -29,6 +29,7 @@ func NewMySQLAdapter(cfg *config.ConnConfig) (*MySQLAdapter, error) {
 		adapter.Close()
 		return nil, err
 	}
+	adapter.ConfigurePool(db)
 	var pingErr error
 	for range 3 {
 		pingErr = db.Ping()
@@ -46,9 +47,9 @@ func NewMySQLAdapter(cfg *config.ConnConfig) (*MySQLAdapter, error) {
 	return adapter, nil
 }
 
-func (a *MySQLAdapter) ReadSchema() (*conn.DatabaseSchema, error) {
+func (a *MySQLAdapter) ReadSchema(filter *config.SchemaFilter) (*conn.DatabaseSchema, error) {
 	dbSchema := &conn.DatabaseSchema{Tables: map[string]*conn.Table{}}
-	tables, err := a.queryTables()
+	tables, err := a.queryTables(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -56,53 +57,159 @@ func (a *MySQLAdapter) ReadSchema() (*conn.DatabaseSchema, error) {
 	return dbSchema, nil
 }
 
-func (a *MySQLAdapter) GetTableDataBatch(table string, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
+func (a *MySQLAdapter) GetTableDataBatch(table *conn.Table, filter *conn.DataFilter, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
 	if len(pk) == 0 {
 		return nil, fmt.Errorf("primary key required for batch scan")
 	}
-	// 构造 SELECT ... FROM table WHERE (pk) > (lastPK) ORDER BY pk LIMIT ?
+	// 构造 SELECT ... FROM table WHERE filter AND (pk) > (lastPK) ORDER BY pk LIMIT ?
+	// 文本主键使用 BINARY 按字节序排序，与程序端的主键比较保持一致
+	from, conds := a.dataSource(table, filter)
 	colList := utils.JoinWrap(cols, "`", ", ")
-	pkList := utils.JoinWrap(pk, "`", ", ")
+	if filter != nil && filter.Query != "" {
+		// 自定义查询的结果列由查询决定
+		colList = "*"
+	}
+	pkExprs := make([]string, len(pk))
+	for i, k := range pk {
+		pkExprs[i] = fmt.Sprintf("`%s`", k)
+		if table.GetColumn(k).IsText() {
+			pkExprs[i] = "BINARY " + pkExprs[i]
+		}
+	}
+	pkList := strings.Join(pkExprs, ", ")
 	orderBy := pkList
-	where := ""
 	var args []any
 	if len(lastPK) > 0 {
-		where = "WHERE ("
-		where += pkList
-		where += ") > ("
+		cond := "("
+		cond += pkList
+		cond += ") > ("
 		for i := range pk {
 			if i > 0 {
-				where += ", "
+				cond += ", "
 			}
-			where += "?"
+			cond += "?"
 			args = append(args, lastPK[i])
 		}
-		where += ")"
+		cond += ")"
+		conds = append(conds, cond)
 	}
-	query := fmt.Sprintf("SELECT %s FROM `%s` %s ORDER BY %s LIMIT ?", colList, table, where, orderBy)
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT ?", colList, from, whereClause(conds), orderBy)
 	args = append(args, limit)
-	rows, err := a.Conn.Query(query, args...)
-	if err != nil {
+	return a.QueryRecords(query, args...)
+}
+
+func (a *MySQLAdapter) GetTableDataPage(table *conn.Table, filter *conn.DataFilter, cols []string, offset, limit int) ([]conn.Record, error) {
+	// 按全部列排序，排序规则需与程序端的值比较一致：
+	// 文本按字节序，JSON 等类型转为文本后按字节序，NULL 排在最后(与 PostgreSQL 一致)
+	from, conds := a.dataSource(table, filter)
+	var orderBy []string
+	for _, c := range cols {
+		orderBy = append(orderBy, fmt.Sprintf("`%s` IS NULL", c))
+		switch table.GetColumn(c).Kind() {
+		case conn.ColumnKindText:
+			orderBy = append(orderBy, fmt.Sprintf("BINARY `%s`", c))
+		case conn.ColumnKindJSON, conn.ColumnKindUnknown:
+			orderBy = append(orderBy, fmt.Sprintf("CAST(`%s` AS BINARY)", c))
+		default:
+			orderBy = append(orderBy, fmt.Sprintf("`%s`", c))
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT ? OFFSET ?",
+		utils.JoinWrap(cols, "`", ", "), from, whereClause(conds), strings.Join(orderBy, ", "))
+	return a.QueryRecords(query, limit, offset)
+}
+
+func (a *MySQLAdapter) GetPKRange(table *conn.Table, filter *conn.DataFilter, pk string) (any, any, error) {
+	from, conds := a.dataSource(table, filter)
+	query := fmt.Sprintf("SELECT MIN(`%s`), MAX(`%s`) FROM %s %s", pk, pk, from, whereClause(conds))
+	var minVal, maxVal any
+	if err := a.Conn.QueryRow(query).Scan(&minVal, &maxVal); err != nil {
+		return nil, nil, err
+	}
+	return minVal, maxVal, nil
+}
+
+func (a *MySQLAdapter) GetRangeChecksum(table *conn.Table, filter *conn.DataFilter, cols []string, pk string, lower, upper any) (*conn.Checksum, error) {
+	// 每行拼接后取 md5 前 15 位(60bit)转为整数求和，与 PostgreSQL 实现的拼接规则保持一致
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = fmt.Sprintf("COALESCE(CAST(`%s` AS CHAR), '\\\\N')", c)
+	}
+	from, conds := a.dataSource(table, filter)
+	conds = append(conds, fmt.Sprintf("`%s` >= ? AND `%s` < ?", pk, pk))
+	query := fmt.Sprintf("SELECT COUNT(*), CAST(COALESCE(SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS('|', %s)), 1, 15), 16, 10) AS UNSIGNED)), 0) AS CHAR) FROM %s %s",
+		strings.Join(exprs, ", "), from, whereClause(conds))
+	checksum := &conn.Checksum{}
+	if err := a.Conn.QueryRow(query, lower, upper).Scan(&checksum.Count, &checksum.Sum); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var result []conn.Record
-	for rows.Next() {
-		vals := make([]any, len(cols))
-		ptrs := make([]any, len(cols))
-		for i := range vals {
-			ptrs[i] = &vals[i]
+	return checksum, nil
+}
+
+func (a *MySQLAdapter) GetTableStats(table *conn.Table, filter *conn.DataFilter, key string, cols []string) (*conn.TableStats, error) {
+	exprs := []string{"COUNT(*)"}
+	if key != "" {
+		expr := statsKeyExpr(table, key)
+		exprs = append(exprs, fmt.Sprintf("MIN(%s), MAX(%s)", expr, expr))
+	}
+	sums := make([]bool, len(cols))
+	for i, c := range cols {
+		exprs = append(exprs, fmt.Sprintf("COUNT(*) - COUNT(`%s`), COUNT(DISTINCT `%s`)", c, c))
+		// 合计转为文本，避免驱动将大数值转换为浮点数丢失精度
+		if col := table.GetColumn(c); col != nil && col.Kind() == conn.ColumnKindNumeric {
+			exprs = append(exprs, fmt.Sprintf("CAST(SUM(`%s`) AS CHAR)", c))
+			sums[i] = true
 		}
-		if err := rows.Scan(ptrs...); err != nil {
-			return nil, err
+	}
+	from, conds := a.dataSource(table, filter)
+	query := fmt.Sprintf("SELECT %s FROM %s %s", strings.Join(exprs, ", "), from, whereClause(conds))
+	stats := &conn.TableStats{Columns: make(map[string]*conn.ColumnStats, len(cols))}
+	dest := []any{&stats.RowCount}
+	if key != "" {
+		dest = append(dest, &stats.MinKey, &stats.MaxKey)
+	}
+	for i, c := range cols {
+		cs := &conn.ColumnStats{}
+		stats.Columns[c] = cs
+		dest = append(dest, &cs.NullCount, &cs.Distinct)
+		if sums[i] {
+			dest = append(dest, &cs.Sum)
 		}
-		rec := conn.Record{}
-		for i, c := range cols {
-			rec[c] = vals[i]
+	}
+	if err := a.Conn.QueryRow(query).Scan(dest...); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// statsKeyExpr MIN/MAX 使用的键列表达式，文本列与分页查询一样使用 BINARY 按字节序比较
+func statsKeyExpr(table *conn.Table, key string) string {
+	if table.GetColumn(key).IsText() {
+		return fmt.Sprintf("BINARY `%s`", key)
+	}
+	return fmt.Sprintf("`%s`", key)
+}
+
+// dataSource 根据过滤条件生成 FROM 子句与 WHERE 条件
+func (a *MySQLAdapter) dataSource(table *conn.Table, filter *conn.DataFilter) (string, []string) {
+	from := fmt.Sprintf("`%s`", table.Name)
+	var conds []string
+	if filter != nil {
+		if filter.Query != "" {
+			from = fmt.Sprintf("(%s) AS `_ds`", filter.Query)
+		}
+		if filter.Where != "" {
+			conds = append(conds, "("+filter.Where+")")
 		}
-		result = append(result, rec)
 	}
-	return result, nil
+	return from, conds
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, " AND ")
 }
 
 func (a *MySQLAdapter) ExtractTable(tableName string) (*conn.Table, error) {
@@ -171,7 +278,8 @@ func (a *MySQLAdapter) GetConfig() *config.ConnConfig {
 	return a.Cfg
 }
 
-func (a *MySQLAdapter) queryTables() (map[string]*conn.Table, error) {
+// queryTables 读取匹配过滤条件的表和视图，不匹配的对象不提取详细结构
+func (a *MySQLAdapter) queryTables(filter *config.SchemaFilter) (map[string]*conn.Table, error) {
 	rows, err := a.Conn.Query(`SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = ?`, a.Cfg.TableSchema)
 	if err != nil {
 		return nil, err
@@ -183,7 +291,11 @@ func (a *MySQLAdapter) queryTables() (map[string]*conn.Table, error) {
 		if err := rows.Scan(&name, &t); err != nil {
 			return nil, err
 		}
-		switch conn.ParseTableType(t) {
+		tableType := conn.ParseTableType(t)
+		if !filter.Match(name, string(tableType)) {
+			continue
+		}
+		switch tableType {
 		case conn.TableTypeTable:
 			table, err := a.ExtractTable(name)
 			if err != nil {
@@ -3,6 +3,7 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jacktea/data-smith/pkg/config"
@@ -32,6 +33,7 @@ func NewPostgresAdapter(cfg *config.ConnConfig) (*PostgresAdapter, error) {
 		adapter.Close()
 		return nil, err
 	}
+	adapter.ConfigurePool(db)
 	var pingErr error
 	for range 3 {
 		pingErr = db.Ping()
@@ -53,9 +55,9 @@ func NewPostgresAdapter(cfg *config.ConnConfig) (*PostgresAdapter, error) {
 	return adapter, nil
 }
 
-func (a *PostgresAdapter) ReadSchema() (*conn.DatabaseSchema, error) {
+func (a *PostgresAdapter) ReadSchema(filter *config.SchemaFilter) (*conn.DatabaseSchema, error) {
 	dbSchema := &conn.DatabaseSchema{Tables: map[string]*conn.Table{}}
-	tables, err := a.queryTables()
+	tables, err := a.queryTables(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -63,55 +65,177 @@ func (a *PostgresAdapter) ReadSchema() (*conn.DatabaseSchema, error) {
 	return dbSchema, nil
 }
 
-func (a *PostgresAdapter) GetTableDataBatch(table string, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
+func (a *PostgresAdapter) GetTableDataBatch(table *conn.Table, filter *conn.DataFilter, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
 	if len(pk) == 0 {
 		return nil, fmt.Errorf("primary key required for batch scan")
 	}
-	// 构造 SELECT ... FROM table WHERE (pk) > (lastPK) ORDER BY pk LIMIT $N
+	// 构造 SELECT ... FROM table WHERE filter AND (pk) > (lastPK) ORDER BY pk LIMIT $N
+	// 文本主键使用 COLLATE "C" 按字节序排序，与程序端的主键比较保持一致
+	from, conds := a.dataSource(table, filter)
 	colList := utils.JoinWrap(cols, "\"", ", ")
-	pkList := utils.JoinWrap(pk, "\"", ", ")
+	if filter != nil && filter.Query != "" {
+		// 自定义查询的结果列由查询决定
+		colList = "*"
+	}
+	pkExprs := make([]string, len(pk))
+	for i, k := range pk {
+		pkExprs[i] = fmt.Sprintf("\"%s\"", k)
+		if table.GetColumn(k).IsText() {
+			pkExprs[i] += " COLLATE \"C\""
+		}
+	}
+	pkList := strings.Join(pkExprs, ", ")
 	orderBy := pkList
-	where := ""
 	var args []any
 	argIdx := 1
 	if len(lastPK) > 0 {
-		where = "WHERE ("
-		where += pkList
-		where += ") > ("
+		cond := "("
+		cond += pkList
+		cond += ") > ("
 		for i := range pk {
 			if i > 0 {
-				where += ", "
+				cond += ", "
 			}
-			where += fmt.Sprintf("$%d", argIdx)
+			cond += fmt.Sprintf("$%d", argIdx)
 			args = append(args, lastPK[i])
 			argIdx++
 		}
-		where += ")"
+		cond += ")"
+		conds = append(conds, cond)
 	}
-	query := fmt.Sprintf("SELECT %s FROM \"%s\" %s ORDER BY %s LIMIT $%d", colList, table, where, orderBy, argIdx)
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT $%d", colList, from, whereClause(conds), orderBy, argIdx)
 	args = append(args, limit)
-	rows, err := a.Conn.Query(query, args...)
-	if err != nil {
+	return a.QueryRecords(query, args...)
+}
+
+func (a *PostgresAdapter) GetTableDataPage(table *conn.Table, filter *conn.DataFilter, cols []string, offset, limit int) ([]conn.Record, error) {
+	// 按全部列排序，排序规则需与程序端的值比较一致：
+	// 文本按字节序，JSON 等无法直接排序的类型转为文本后按字节序，NULL 排在最后(PostgreSQL 默认)
+	from, conds := a.dataSource(table, filter)
+	orderBy := make([]string, len(cols))
+	for i, c := range cols {
+		switch table.GetColumn(c).Kind() {
+		case conn.ColumnKindText:
+			orderBy[i] = fmt.Sprintf("\"%s\" COLLATE \"C\"", c)
+		case conn.ColumnKindJSON, conn.ColumnKindUnknown:
+			orderBy[i] = fmt.Sprintf("\"%s\"::text COLLATE \"C\"", c)
+		default:
+			orderBy[i] = fmt.Sprintf("\"%s\"", c)
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT $1 OFFSET $2",
+		utils.JoinWrap(cols, "\"", ", "), from, whereClause(conds), strings.Join(orderBy, ", "))
+	return a.QueryRecords(query, limit, offset)
+}
+
+func (a *PostgresAdapter) GetPKRange(table *conn.Table, filter *conn.DataFilter, pk string) (any, any, error) {
+	from, conds := a.dataSource(table, filter)
+	query := fmt.Sprintf("SELECT MIN(\"%s\"), MAX(\"%s\") FROM %s %s", pk, pk, from, whereClause(conds))
+	var minVal, maxVal any
+	if err := a.Conn.QueryRow(query).Scan(&minVal, &maxVal); err != nil {
+		return nil, nil, err
+	}
+	return minVal, maxVal, nil
+}
+
+func (a *PostgresAdapter) GetRangeChecksum(table *conn.Table, filter *conn.DataFilter, cols []string, pk string, lower, upper any) (*conn.Checksum, error) {
+	// 每行拼接后取 md5 前 15 位(60bit)转为整数求和，与 MySQL 实现的拼接规则保持一致
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = fmt.Sprintf("COALESCE(\"%s\"::text, '\\N')", c)
+	}
+	from, conds := a.dataSource(table, filter)
+	conds = append(conds, fmt.Sprintf("\"%s\" >= $1 AND \"%s\" < $2", pk, pk))
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(('x' || SUBSTR(MD5(CONCAT_WS('|', %s)), 1, 15))::bit(60)::bigint), 0)::text
+		FROM %s %s`, strings.Join(exprs, ", "), from, whereClause(conds))
+	checksum := &conn.Checksum{}
+	if err := a.Conn.QueryRow(query, lower, upper).Scan(&checksum.Count, &checksum.Sum); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var result []conn.Record
-	for rows.Next() {
-		vals := make([]any, len(cols))
-		ptrs := make([]any, len(cols))
-		for i := range vals {
-			ptrs[i] = &vals[i]
+	return checksum, nil
+}
+
+func (a *PostgresAdapter) GetTableStats(table *conn.Table, filter *conn.DataFilter, key string, cols []string) (*conn.TableStats, error) {
+	exprs := []string{"COUNT(*)"}
+	if key != "" {
+		expr := statsKeyExpr(table, key)
+		exprs = append(exprs, fmt.Sprintf("MIN(%s), MAX(%s)", expr, expr))
+	}
+	sums := make([]bool, len(cols))
+	for i, c := range cols {
+		exprs = append(exprs, fmt.Sprintf("COUNT(*) - COUNT(\"%s\"), COUNT(DISTINCT %s)", c, statsDistinctExpr(table, c)))
+		// 合计转为文本，避免驱动将大数值转换为浮点数丢失精度
+		if col := table.GetColumn(c); col != nil && col.Kind() == conn.ColumnKindNumeric {
+			exprs = append(exprs, fmt.Sprintf("SUM(\"%s\")::text", c))
+			sums[i] = true
 		}
-		if err := rows.Scan(ptrs...); err != nil {
-			return nil, err
+	}
+	from, conds := a.dataSource(table, filter)
+	query := fmt.Sprintf("SELECT %s FROM %s %s", strings.Join(exprs, ", "), from, whereClause(conds))
+	stats := &conn.TableStats{Columns: make(map[string]*conn.ColumnStats, len(cols))}
+	dest := []any{&stats.RowCount}
+	if key != "" {
+		dest = append(dest, &stats.MinKey, &stats.MaxKey)
+	}
+	for i, c := range cols {
+		cs := &conn.ColumnStats{}
+		stats.Columns[c] = cs
+		dest = append(dest, &cs.NullCount, &cs.Distinct)
+		if sums[i] {
+			dest = append(dest, &cs.Sum)
 		}
-		rec := conn.Record{}
-		for i, c := range cols {
-			rec[c] = vals[i]
+	}
+	if err := a.Conn.QueryRow(query).Scan(dest...); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// statsKeyExpr MIN/MAX 使用的键列表达式：文本列与分页查询一样使用 COLLATE "C"，
+// uuid、boolean、bytea 等没有 MIN/MAX 聚合的类型转为文本
+func statsKeyExpr(table *conn.Table, key string) string {
+	expr := fmt.Sprintf("\"%s\"", key)
+	switch table.GetColumn(key).Kind() {
+	case conn.ColumnKindNumeric, conn.ColumnKindTime:
+		return expr
+	case conn.ColumnKindText:
+		return expr + " COLLATE \"C\""
+	default:
+		return expr + "::text COLLATE \"C\""
+	}
+}
+
+// statsDistinctExpr COUNT(DISTINCT) 使用的列表达式，json 等没有相等运算的类型转为文本
+func statsDistinctExpr(table *conn.Table, col string) string {
+	expr := fmt.Sprintf("\"%s\"", col)
+	switch table.GetColumn(col).Kind() {
+	case conn.ColumnKindUnknown, conn.ColumnKindJSON:
+		return expr + "::text"
+	default:
+		return expr
+	}
+}
+
+// dataSource 根据过滤条件生成 FROM 子句与 WHERE 条件
+func (a *PostgresAdapter) dataSource(table *conn.Table, filter *conn.DataFilter) (string, []string) {
+	from := fmt.Sprintf("\"%s\"", table.Name)
+	var conds []string
+	if filter != nil {
+		if filter.Query != "" {
+			from = fmt.Sprintf("(%s) AS \"_ds\"", filter.Query)
+		}
+		if filter.Where != "" {
+			conds = append(conds, "("+filter.Where+")")
 		}
-		result = append(result, rec)
 	}
-	return result, nil
+	return from, conds
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, " AND ")
 }
 
 func (a *PostgresAdapter) ExtractTable(tableName string) (*conn.Table, error) {
@@ -180,7 +304,8 @@ func (a *PostgresAdapter) GetConfig() *config.ConnConfig {
 	return a.Cfg
 }
 
-func (a *PostgresAdapter) queryTables() (map[string]*conn.Table, error) {
+// queryTables 读取匹配过滤条件的表和视图，不匹配的对象不提取详细结构
+func (a *PostgresAdapter) queryTables(filter *config.SchemaFilter) (map[string]*conn.Table, error) {
 	rows, err := a.Conn.Query(`SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = $1`, a.Cfg.TableSchema)
 	if err != nil {
 		return nil, err
@@ -192,7 +317,11 @@ func (a *PostgresAdapter) queryTables() (map[string]*conn.Table, error) {
 		if err := rows.Scan(&name, &t); err != nil {
 			return nil, err
 		}
-		switch conn.ParseTableType(t) {
+		tableType := conn.ParseTableType(t)
+		if !filter.Match(name, string(tableType)) {
+			continue
+		}
+		switch tableType {
 		case conn.TableTypeTable:
 			table, err := a.ExtractTable(name)
 			if err != nil {
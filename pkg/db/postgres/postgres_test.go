@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
 )
 
 func TestExtractTableDetail(t *testing.T) {
@@ -55,3 +56,30 @@ func TestExtractViewDetail(t *testing.T) {
 	}
 	t.Logf("View: %s", string(json))
 }
+
+func TestStatsExpr(t *testing.T) {
+	tbl := &conn.Table{Columns: map[string]*conn.Column{
+		"id":   {Name: "id", DataType: "bigint"},
+		"code": {Name: "code", DataType: "character varying"},
+		"uid":  {Name: "uid", DataType: "uuid"},
+		"flag": {Name: "flag", DataType: "boolean"},
+		"doc":  {Name: "doc", DataType: "json"},
+	}}
+	keys := map[string]string{
+		"id":   `"id"`,
+		"code": `"code" COLLATE "C"`,
+		"uid":  `"uid"::text COLLATE "C"`,
+		"flag": `"flag"::text COLLATE "C"`,
+	}
+	for key, expect := range keys {
+		if got := statsKeyExpr(tbl, key); got != expect {
+			t.Errorf("statsKeyExpr(%s) = %s, expect %s", key, got, expect)
+		}
+	}
+	if got := statsDistinctExpr(tbl, "doc"); got != `"doc"::text` {
+		t.Errorf("statsDistinctExpr(doc) = %s", got)
+	}
+	if got := statsDistinctExpr(tbl, "uid"); got != `"uid"` {
+		t.Errorf("statsDistinctExpr(uid) = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+// Checkpoint 流式比对的断点，记录两端都已处理完成的位置
+type Checkpoint struct {
+	// LastKey 已处理完的最大键值，键值不大于它的行都已比对并输出
+	LastKey []string `json:"lastKey,omitempty"`
+	// SrcRows/TgtRows 两端已处理的行数，按整行比对(没有键)时作为续传的偏移量
+	SrcRows int64 `json:"srcRows"`
+	TgtRows int64 `json:"tgtRows"`
+	// Stats 截至断点已输出的差异统计
+	Stats DiffStats `json:"stats"`
+}
+
+// CheckpointSink 支持断点续传的 DiffSink
+type CheckpointSink interface {
+	DiffSink
+	// Checkpoint 持久化已写入的差异，返回后这些差异不会因进程中断而丢失
+	Checkpoint() error
+}
+
+// ResumeOptions 断点续传参数
+type ResumeOptions struct {
+	// From 上次保存的断点，为 nil 时从头开始
+	From *Checkpoint
+	// Every 每处理多少行保存一次断点，默认为 batchSize
+	Every int
+	// Save 保存断点，调用时 sink 已完成 Checkpoint
+	Save func(cp *Checkpoint) error
+}
+
+// StreamCompareDataResumable 可断点续传的流式比对
+// 每处理 Every 行先调用 sink.Checkpoint 持久化输出，再通过 Save 保存断点；
+// 从断点继续时两端都从 LastKey 之后(整行比对时从已处理的行数之后)读取
+func StreamCompareDataResumable(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize int, sink CheckpointSink, opts ResumeOptions) error {
+	progress := &mergeProgress{every: opts.Every, sink: sink, save: opts.Save}
+	if progress.every <= 0 {
+		progress.every = batchSize
+	}
+	if opts.From != nil {
+		progress.cp = *opts.From
+	}
+	write := sinkHandler(sink)
+	handle := func(diffType DiffType, srcRow, tgtRow conn.Record, changed []string) error {
+		progress.cp.Stats.Add(diffType)
+		return write(diffType, srcRow, tgtRow, changed)
+	}
+	return streamCompare(srcDB, tgtDB, rule, batchSize, handle, progress)
+}
+
+// mergeProgress 归并比对的进度
+type mergeProgress struct {
+	every   int
+	pending int
+	keys    []string
+	cp      Checkpoint
+	sink    CheckpointSink
+	save    func(cp *Checkpoint) error
+}
+
+// resume 将迭代器定位到断点之后
+func (p *mergeProgress) resume(srcIter, tgtIter *rowBatchIterator) {
+	if srcIter.fullRow {
+		srcIter.offset = int(p.cp.SrcRows)
+		tgtIter.offset = int(p.cp.TgtRows)
+		return
+	}
+	p.keys = srcIter.pk
+	if len(p.cp.LastKey) == len(srcIter.pk) {
+		lastPK := make([]any, len(p.cp.LastKey))
+		for i, v := range p.cp.LastKey {
+			lastPK[i] = v
+		}
+		srcIter.lastPK = lastPK
+		tgtIter.lastPK = lastPK
+	}
+}
+
+// advance 记录一步归并，cmp 为本步两端当前行的比较结果
+// 归并按键升序推进，本步消费的行的键即为两端都已处理完的位置
+func (p *mergeProgress) advance(cmp int, srcRow, tgtRow conn.Record) error {
+	row := srcRow
+	if cmp <= 0 {
+		p.cp.SrcRows++
+	}
+	if cmp >= 0 {
+		p.cp.TgtRows++
+		if cmp > 0 {
+			row = tgtRow
+		}
+	}
+	if p.keys != nil {
+		p.cp.LastKey = make([]string, len(p.keys))
+		for i, k := range p.keys {
+			p.cp.LastKey[i] = checkpointValue(row[k])
+		}
+	}
+	p.pending++
+	if p.pending < p.every {
+		return nil
+	}
+	p.pending = 0
+	if err := p.sink.Checkpoint(); err != nil {
+		return err
+	}
+	cp := p.cp
+	return p.save(&cp)
+}
+
+// checkpointValue 将键值转换为可写入断点文件并可作为查询参数的文本
+// 时间按驱动返回时的时区输出墙上时间，不带时区偏移，旧版本 MySQL 不接受带偏移的时间文本
+func checkpointValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05.999999")
+	}
+	return toString(v)
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+// checkpointSink 模拟输出文件：Checkpoint 之前的差异才算已持久化
+type checkpointSink struct {
+	pending   []string
+	committed []string
+}
+
+func (s *checkpointSink) Write(diffType DiffType, srcRow, tgtRow conn.Record) error {
+	row := srcRow
+	if row == nil {
+		row = tgtRow
+	}
+	s.pending = append(s.pending, fmt.Sprintf("%s:%v", diffType, row["id"]))
+	return nil
+}
+
+func (s *checkpointSink) Checkpoint() error {
+	s.committed = append(s.committed, s.pending...)
+	s.pending = nil
+	return nil
+}
+
+func (s *checkpointSink) Close() error {
+	return s.Checkpoint()
+}
+
+func TestStreamCompareDataResumable(t *testing.T) {
+	cols := []string{"id", "a"}
+	types := map[string]string{"id": "varchar", "a": "varchar"}
+	newDBs := func() (*mockDB, *mockDB) {
+		src := &mockDB{cols: cols, pk: []string{"id"}, types: types, rows: []conn.Record{
+			{"id": "a", "a": "1"}, {"id": "b", "a": "2"}, {"id": "d", "a": "4"}, {"id": "e", "a": "5"}, {"id": "f", "a": "6"},
+		}}
+		tgt := &mockDB{cols: cols, pk: []string{"id"}, types: types, rows: []conn.Record{
+			{"id": "b", "a": "x"}, {"id": "c", "a": "3"}, {"id": "e", "a": "5"}, {"id": "g", "a": "7"},
+		}}
+		return src, tgt
+	}
+	rule := CreateCompareRule(&conn.Table{Name: "t"}, []string{"a"})
+	expect := []string{"ADD:a", "MODIFY:b", "DROP:c", "ADD:d", "ADD:f", "DROP:g"}
+
+	// 第二次保存断点时模拟进程中断
+	src, tgt := newDBs()
+	sink := &checkpointSink{}
+	var saved *Checkpoint
+	var kept []string
+	interrupted := errors.New("interrupted")
+	err := StreamCompareDataResumable(src, tgt, rule, 2, sink, ResumeOptions{Every: 2, Save: func(cp *Checkpoint) error {
+		if saved != nil {
+			return interrupted
+		}
+		saved = cp
+		kept = append([]string(nil), sink.committed...)
+		return nil
+	}})
+	if !errors.Is(err, interrupted) {
+		t.Fatalf("expect interrupted, got %v", err)
+	}
+	if saved == nil || !reflect.DeepEqual(saved.LastKey, []string{"b"}) || saved.SrcRows != 2 || saved.TgtRows != 1 || saved.Stats.Total() != 2 {
+		t.Fatalf("unexpected checkpoint %+v", saved)
+	}
+
+	// 丢弃最后保存的断点之后的输出，从断点继续
+	src, tgt = newDBs()
+	resumed := &checkpointSink{}
+	err = StreamCompareDataResumable(src, tgt, rule, 2, resumed, ResumeOptions{From: saved, Every: 2, Save: func(cp *Checkpoint) error {
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	got := append(kept, resumed.committed...)
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestCheckpointValueTime(t *testing.T) {
+	v := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.FixedZone("", 8*3600))
+	if got := checkpointValue(v); got != "2024-01-02 03:04:05.123456" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+const (
+	CompareNumeric   = "numeric"
+	CompareTimestamp = "timestamp"
+	CompareText      = "text"
+	CompareJSON      = "json"
+	CompareExact     = "exact"
+)
+
+// ValueComparator 判断两端的列值是否相等
+type ValueComparator interface {
+	Equal(a, b any) bool
+}
+
+// ExactComparator 按文本比较
+type ExactComparator struct{}
+
+func (ExactComparator) Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return toString(a) == toString(b)
+}
+
+// floatRelTolerance 浮点列默认的相对误差，略大于 float32 的精度，
+// MySQL FLOAT 扩展为 float64 后与 PostgreSQL double 的值(如 0.1 与 0.10000000149)视为相等
+const floatRelTolerance = 1e-6
+
+// NumericComparator 按数值比较，差的绝对值不超过 Tolerance 时视为相等
+// 未指定 Tolerance 时 DECIMAL/NUMERIC 精确比较，Float 为 true 时按相对误差比较
+type NumericComparator struct {
+	Tolerance *big.Rat
+	Float     bool
+}
+
+func (c NumericComparator) Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ar, aok := toRat(a)
+	br, bok := toRat(b)
+	if !aok || !bok {
+		return ExactComparator{}.Equal(a, b)
+	}
+	diff := new(big.Rat).Sub(ar, br)
+	if c.Tolerance == nil {
+		if c.Float {
+			af, _ := ar.Float64()
+			bf, _ := br.Float64()
+			return math.Abs(af-bf) <= floatRelTolerance*math.Max(math.Abs(af), math.Abs(bf))
+		}
+		return diff.Sign() == 0
+	}
+	return diff.Abs(diff).Cmp(c.Tolerance) <= 0
+}
+
+// TimeComparator 按时间点比较，Precision 大于 0 时先截断到该精度
+// 用于消除 MySQL(默认秒/毫秒) 与 PostgreSQL(微秒) 的精度差异
+type TimeComparator struct {
+	Precision time.Duration
+	// WallClock 为 true 时只比较年月日时分秒，忽略时区。用于不带时区的类型，
+	// 驱动按各自的 loc(如 MySQL loc=Local 与 PostgreSQL 的 UTC)解析后时间点不同但墙上时间一致
+	WallClock bool
+}
+
+func (c TimeComparator) Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	at, aok := toTime(a)
+	bt, bok := toTime(b)
+	if !aok || !bok {
+		return ExactComparator{}.Equal(a, b)
+	}
+	if c.WallClock {
+		at, bt = wallClock(at), wallClock(bt)
+	}
+	if c.Precision > 0 {
+		at = at.Truncate(c.Precision)
+		bt = bt.Truncate(c.Precision)
+	}
+	return at.Equal(bt)
+}
+
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// TextComparator 文本比较，可忽略大小写与空白差异
+type TextComparator struct {
+	IgnoreCase       bool
+	IgnoreWhitespace bool
+}
+
+func (c TextComparator) Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	as, bs := toString(a), toString(b)
+	if c.IgnoreWhitespace {
+		as = strings.Join(strings.Fields(as), " ")
+		bs = strings.Join(strings.Fields(bs), " ")
+	}
+	if c.IgnoreCase {
+		return strings.EqualFold(as, bs)
+	}
+	return as == bs
+}
+
+// JSONComparator 按 JSON 语义比较，忽略对象键顺序与格式差异
+type JSONComparator struct{}
+
+func (JSONComparator) Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ab, bb := toBytes(a), toBytes(b)
+	if bytes.Equal(ab, bb) {
+		return true
+	}
+	var av, bv any
+	if json.Unmarshal(ab, &av) != nil || json.Unmarshal(bb, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// DefaultComparator 根据列类别选择比较方式
+// 时间与 JSON 列在 MySQL 与 PostgreSQL 之间的文本表示不同，需按语义比较
+func DefaultComparator(kind conn.ColumnKind) ValueComparator {
+	switch kind {
+	case conn.ColumnKindNumeric, conn.ColumnKindBool:
+		return NumericComparator{}
+	case conn.ColumnKindTime:
+		return TimeComparator{}
+	case conn.ColumnKindJSON:
+		return JSONComparator{}
+	default:
+		return ExactComparator{}
+	}
+}
+
+// ColumnComparator 按列选择默认比较方式，不带时区的时间列按墙上时间比较，浮点列按相对误差比较
+func ColumnComparator(col *conn.Column) ValueComparator {
+	if col.Kind() == conn.ColumnKindTime {
+		return TimeComparator{WallClock: !col.HasTimeZone()}
+	}
+	if col.IsFloat() {
+		return NumericComparator{Float: true}
+	}
+	return DefaultComparator(col.Kind())
+}
+
+// NewColumnComparator 根据列配置创建比较器，未指定比较方式时按列选择
+func NewColumnComparator(col *conn.Column, rule config.ColumnRule) (ValueComparator, error) {
+	compare := rule.Compare
+	if compare == "" {
+		switch {
+		case rule.Tolerance != 0:
+			compare = CompareNumeric
+		case rule.Precision != "":
+			compare = CompareTimestamp
+		case rule.IgnoreCase || rule.IgnoreWhitespace:
+			compare = CompareText
+		default:
+			return ColumnComparator(col), nil
+		}
+	}
+	switch compare {
+	case CompareNumeric:
+		c := NumericComparator{Float: col.IsFloat()}
+		if rule.Tolerance != 0 {
+			c.Tolerance = new(big.Rat)
+			if c.Tolerance.SetFloat64(rule.Tolerance) == nil {
+				return nil, fmt.Errorf("invalid tolerance: %v", rule.Tolerance)
+			}
+			c.Tolerance.Abs(c.Tolerance)
+		}
+		return c, nil
+	case CompareTimestamp:
+		precision, err := parsePrecision(rule.Precision)
+		if err != nil {
+			return nil, err
+		}
+		return TimeComparator{Precision: precision, WallClock: !col.HasTimeZone()}, nil
+	case CompareText:
+		return TextComparator{IgnoreCase: rule.IgnoreCase, IgnoreWhitespace: rule.IgnoreWhitespace}, nil
+	case CompareJSON:
+		return JSONComparator{}, nil
+	case CompareExact:
+		return ExactComparator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown compare type: %s", compare)
+	}
+}
+
+func parsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "":
+		return 0, nil
+	case "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "us":
+		return time.Microsecond, nil
+	default:
+		return 0, fmt.Errorf("unknown timestamp precision: %s", precision)
+	}
+}
@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+func TestCreateCompareRuleFromConfig(t *testing.T) {
+	tbl := &conn.Table{Name: "t", Columns: map[string]*conn.Column{
+		"id":         {Name: "id", DataType: "bigint"},
+		"price":      {Name: "price", DataType: "numeric"},
+		"name":       {Name: "name", DataType: "varchar"},
+		"attrs":      {Name: "attrs", DataType: "jsonb"},
+		"created_at": {Name: "created_at", DataType: "timestamp"},
+		"updated_at": {Name: "updated_at", DataType: "timestamp"},
+	}}
+	rule, err := CreateCompareRuleFromConfig(tbl, config.Rule{
+		Table:         "t",
+		IgnoreColumns: []string{"updated_at"},
+		Columns: map[string]config.ColumnRule{
+			"price":      {Tolerance: 0.01},
+			"name":       {IgnoreCase: true, IgnoreWhitespace: true},
+			"created_at": {Precision: "ms"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	src := conn.Record{
+		"id": int64(1), "price": "10.005", "name": "  Foo   Bar", "attrs": `{"a":1,"b":[1,2]}`,
+		"created_at": ts, "updated_at": ts,
+	}
+	tgt := conn.Record{
+		"id": []byte("1"), "price": []byte("10.00"), "name": []byte("foo bar"), "attrs": []byte(`{"b": [1, 2], "a": 1}`),
+		"created_at": []byte("2024-01-02 03:04:05.123"), "updated_at": []byte("2020-01-01 00:00:00"),
+	}
+	if !rule.IsEqual(src, tgt) {
+		t.Errorf("expect equal")
+	}
+
+	tests := []struct {
+		col string
+		val any
+	}{
+		{"price", "10.02"},
+		{"name", "foo baz"},
+		{"attrs", `{"a":1,"b":[2,1]}`},
+		{"created_at", "2024-01-02 03:04:05.124"},
+		{"id", nil},
+	}
+	for _, tt := range tests {
+		modified := conn.Record{}
+		for k, v := range tgt {
+			modified[k] = v
+		}
+		modified[tt.col] = tt.val
+		if rule.IsEqual(src, modified) {
+			t.Errorf("%s=%v: expect not equal", tt.col, tt.val)
+		}
+	}
+
+	if _, err := CreateCompareRuleFromConfig(tbl, config.Rule{
+		Table:   "t",
+		Columns: map[string]config.ColumnRule{"created_at": {Precision: "minute"}},
+	}); err == nil {
+		t.Errorf("expect error for unknown precision")
+	}
+}
+
+func TestTimeComparatorWallClock(t *testing.T) {
+	// MySQL loc=Local 与 PostgreSQL timestamp without time zone(按 UTC 解析)读出的同一墙上时间
+	local := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	naive := ColumnComparator(&conn.Column{Name: "t", DataType: "timestamp without time zone"})
+	if !naive.Equal(local, utc) {
+		t.Errorf("naive timestamps with the same wall clock should be equal")
+	}
+	if naive.Equal(local, utc.Add(time.Hour)) {
+		t.Errorf("different wall clock should not be equal")
+	}
+	zoned := ColumnComparator(&conn.Column{Name: "t", DataType: "timestamp with time zone"})
+	if zoned.Equal(local, utc) {
+		t.Errorf("timestamptz should compare instants")
+	}
+	if !zoned.Equal(local, utc.Add(-8*time.Hour)) {
+		t.Errorf("same instant should be equal")
+	}
+}
+
+func TestFloatColumnComparator(t *testing.T) {
+	// MySQL FLOAT 扩展为 float64 后与 PostgreSQL double 读出的 0.1
+	widened := float64(float32(0.1))
+	for _, dt := range []string{"float", "double precision", "real"} {
+		cmp := ColumnComparator(&conn.Column{Name: "f", DataType: dt})
+		if !cmp.Equal(widened, 0.1) {
+			t.Errorf("%s: %v and 0.1 should be equal", dt, widened)
+		}
+		if cmp.Equal(0.1, 0.2) {
+			t.Errorf("%s: 0.1 and 0.2 should not be equal", dt)
+		}
+	}
+	if ColumnComparator(&conn.Column{Name: "d", DataType: "decimal"}).Equal(widened, 0.1) {
+		t.Errorf("decimal should compare exactly")
+	}
+}
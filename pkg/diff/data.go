@@ -2,20 +2,60 @@ package diff
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/logger"
 )
 
 func StreamCompareData(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize int, handle func(diffType DiffType, srcRow, tgtRow conn.Record)) error {
-	cols, pks, err := getTableColumns(tgtDB, rule.GetTable())
+	return streamCompare(srcDB, tgtDB, rule, batchSize, wrapHandler(handle), nil)
+}
+
+// StreamCompareDataToSink 流式比对数据，差异逐行写入 sink，内存占用与表大小无关
+// sink 由调用方负责关闭
+func StreamCompareDataToSink(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize int, sink DiffSink) error {
+	return streamCompare(srcDB, tgtDB, rule, batchSize, sinkHandler(sink), nil)
+}
+
+// streamCompare 流式归并比对，progress 不为 nil 时从其记录的位置继续并定期保存断点
+func streamCompare(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize int, handle DiffHandler, progress *mergeProgress) error {
+	tbl, cols, pks, err := getRuleColumns(tgtDB, rule)
 	if err != nil {
 		return err
 	}
-	srcIter := newRowBatchIterator(srcDB, rule.GetTable(), cols, pks, batchSize)
-	tgtIter := newRowBatchIterator(tgtDB, rule.GetTable(), cols, pks, batchSize)
+	fullRow := len(pks) == 0
+	if fullRow {
+		// 没有可用的键时按全部列排序归并，重复行逐条配对，多出的行作为新增或删除
+		logger.Warnf("表 %s 没有主键或非空唯一索引, 按整行比对", tbl.Name)
+		pks = cols
+	}
+	cmpPK := newPKComparator(tbl, pks)
+	srcIter := newRowBatchIterator(srcDB, tbl, rule.GetFilter(), cols, pks, batchSize)
+	tgtIter := newRowBatchIterator(tgtDB, tbl, rule.GetFilter(), cols, pks, batchSize)
+	srcIter.fullRow, tgtIter.fullRow = fullRow, fullRow
+	if filter := rule.GetFilter(); filter != nil && filter.Query != "" {
+		// 结果缺少比对列时两端的值都为 nil，会被误判为一致
+		required := slices.Clone(pks)
+		for _, c := range rule.GetColumns() {
+			if !slices.Contains(required, c) {
+				required = append(required, c)
+			}
+		}
+		srcIter.required, tgtIter.required = required, required
+	}
+	if progress != nil {
+		progress.resume(srcIter, tgtIter)
+	}
 	defer srcIter.Close()
 	defer tgtIter.Close()
+	return mergeCompare(srcIter, tgtIter, cmpPK, rule, handle, progress)
+}
 
+// mergeCompare 对两个按主键有序的迭代器做归并比较
+func mergeCompare(srcIter, tgtIter *rowBatchIterator, cmpPK *pkComparator, rule ICompareRule, handle DiffHandler, progress *mergeProgress) error {
 	var srcBuf, tgtBuf []conn.Record
 	var srcIdx, tgtIdx int
 	var srcDone, tgtDone bool
@@ -60,75 +100,140 @@ func StreamCompareData(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize
 		} else if tgtRow == nil {
 			cmp = -1
 		} else {
-			cmp = comparePKRecord(srcRow, tgtRow, pks)
+			cmp = cmpPK.Compare(srcRow, tgtRow)
 		}
+		var err error
 		if cmp < 0 {
-			handle(DiffTypeAdd, srcRow, nil)
+			err = handle(DiffTypeAdd, srcRow, nil, nil)
 			srcIdx++
 		} else if cmp > 0 {
-			handle(DiffTypeDrop, nil, tgtRow)
+			err = handle(DiffTypeDrop, nil, tgtRow, nil)
 			tgtIdx++
 		} else {
-			if !rule.IsEqual(srcRow, tgtRow) {
-				handle(DiffTypeModify, srcRow, tgtRow)
+			if changed := rule.ChangedColumns(srcRow, tgtRow); len(changed) > 0 {
+				err = handle(DiffTypeModify, srcRow, tgtRow, changed)
 			}
 			srcIdx++
 			tgtIdx++
 		}
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			if err := progress.advance(cmp, srcRow, tgtRow); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 func StreamCompareDataToDiff(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, batchSize int) (*DataDiff, error) {
 	diff := &DataDiff{}
-	err := StreamCompareData(srcDB, tgtDB, rule, batchSize, func(diffType DiffType, srcRow, tgtRow conn.Record) {
-		switch diffType {
-		case DiffTypeAdd:
-			diff.Added = append(diff.Added, srcRow)
-		case DiffTypeDrop:
-			diff.Dropped = append(diff.Dropped, tgtRow)
-		case DiffTypeModify:
-			diff.Modified = append(diff.Modified, ModifiedRow{Old: tgtRow, New: srcRow})
-		}
-	})
+	err := StreamCompareDataToSink(srcDB, tgtDB, rule, batchSize, diff)
 	if err != nil {
 		return nil, err
 	}
 	return diff, nil
 }
 
-func newRowBatchIterator(db conn.DBAdapter, table string, cols, pk []string, batchSize int) *rowBatchIterator {
+func newRowBatchIterator(db conn.DBAdapter, table *conn.Table, filter *conn.DataFilter, cols, pk []string, batchSize int) *rowBatchIterator {
 	return &rowBatchIterator{
-		db:    db,
-		table: table,
-		cols:  cols,
-		pk:    pk,
-		limit: batchSize,
+		db:       db,
+		table:    table,
+		filter:   filter,
+		cols:     cols,
+		pk:       pk,
+		limit:    batchSize,
+		prefetch: true,
 	}
 }
 
+type batchResult struct {
+	rows []conn.Record
+	err  error
+}
+
 type rowBatchIterator struct {
 	db     conn.DBAdapter
-	table  string
+	table  *conn.Table
+	filter *conn.DataFilter
 	cols   []string
 	pk     []string
 	limit  int
 	lastPK []any
-	buf    []conn.Record
-	idx    int
+	upper  conn.Record   // 主键上界(不含)，为 nil 时不限制
+	cmpPK  *pkComparator // 与 upper 配合使用
 	closed bool
+
+	// fullRow 为 true 时按全部列排序并以偏移量分页，用于没有键的表
+	fullRow bool
+	offset  int
+
+	// required 自定义查询的结果必须包含的列，在第一批数据上检查
+	required []string
+
+	// prefetch 为 true 时在后台协程中预取下一批数据，
+	// 源端与目标端的查询因此可以并发执行，并与比对过程重叠
+	prefetch  bool
+	results   chan batchResult
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
 func (it *rowBatchIterator) NextBatch() ([]conn.Record, error) {
+	if !it.prefetch {
+		return it.fetchBatch()
+	}
+	if it.results == nil {
+		it.startPrefetch()
+	}
+	r, ok := <-it.results
+	if !ok {
+		return nil, nil
+	}
+	return r.rows, r.err
+}
+
+func (it *rowBatchIterator) startPrefetch() {
+	results := make(chan batchResult, 1)
+	stop := make(chan struct{})
+	it.results = results
+	it.stop = stop
+	go func() {
+		defer close(results)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			batch, err := it.fetchBatch()
+			select {
+			case results <- batchResult{rows: batch, err: err}:
+			case <-stop:
+				return
+			}
+			if batch == nil || err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// fetchBatch 从数据库拉取下一批数据，返回 nil 表示已读取完毕
+func (it *rowBatchIterator) fetchBatch() ([]conn.Record, error) {
 	if it.closed {
 		return nil, nil
 	}
-	if it.buf != nil && it.idx < len(it.buf) {
-		batch := it.buf[it.idx:]
-		it.idx = len(it.buf)
-		return batch, nil
+	var batch []conn.Record
+	var err error
+	if it.fullRow {
+		batch, err = it.db.GetTableDataPage(it.table, it.filter, it.cols, it.offset, it.limit)
+		it.offset += len(batch)
+	} else {
+		batch, err = it.db.GetTableDataBatch(it.table, it.filter, it.cols, it.pk, it.lastPK, it.limit)
 	}
-	batch, err := it.db.GetTableDataBatch(it.table, it.cols, it.pk, it.lastPK, it.limit)
 	if err != nil {
 		return nil, err
 	}
@@ -136,31 +241,40 @@ func (it *rowBatchIterator) NextBatch() ([]conn.Record, error) {
 		it.closed = true
 		return nil, nil
 	}
-	it.buf = batch
-	it.idx = len(batch)
-	if len(batch) > 0 {
-		it.lastPK = extractPK(batch[len(batch)-1], it.pk)
+	if len(it.required) > 0 {
+		if err := checkQueryColumns(it.table.Name, batch[0], it.required); err != nil {
+			return nil, err
+		}
+		it.required = nil
+	}
+	it.lastPK = extractPK(batch[len(batch)-1], it.pk)
+	if it.upper != nil {
+		for i, row := range batch {
+			if it.cmpPK.Compare(row, it.upper) >= 0 {
+				batch = batch[:i]
+				it.closed = true
+				break
+			}
+		}
+		if len(batch) == 0 {
+			return nil, nil
+		}
 	}
 	return batch, nil
 }
 
+// Close 关闭迭代器，并等待后台预取协程退出，可重复调用
 func (it *rowBatchIterator) Close() error {
-	it.closed = true
-	return nil
-}
-
-// comparePKRecord 比较主键值
-func comparePKRecord(a, b conn.Record, pk []string) int {
-	for _, k := range pk {
-		av := fmt.Sprintf("%v", a[k])
-		bv := fmt.Sprintf("%v", b[k])
-		if av < bv {
-			return -1
-		} else if av > bv {
-			return 1
+	it.closeOnce.Do(func() {
+		if it.results == nil {
+			it.closed = true
+			return
 		}
-	}
-	return 0
+		close(it.stop)
+		for range it.results {
+		}
+	})
+	return nil
 }
 
 // extractPK 生成主键值
@@ -172,19 +286,40 @@ func extractPK(row conn.Record, pk []string) []any {
 	return res
 }
 
-// getTableColumns 获取表的列和主键
-func getTableColumns(db conn.DBAdapter, table string) ([]string, []string, error) {
+// getTableColumns 获取表结构、按位置排序的列和行键(主键或非空唯一索引)
+func getTableColumns(db conn.DBAdapter, table string) (*conn.Table, []string, []string, error) {
 	tbl, err := db.ExtractTable(table)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	if tbl == nil {
+		return nil, nil, nil, fmt.Errorf("table %s not found", table)
+	}
+	return tbl, tbl.GetColumnNamesByPosition(), tbl.GetRowKeyColumns(), nil
+}
+
+// checkQueryColumns 检查自定义查询的结果行是否包含规则需要的键列与比对列
+func checkQueryColumns(table string, row conn.Record, required []string) error {
+	var missing []string
+	for _, c := range required {
+		if _, ok := row[c]; !ok {
+			missing = append(missing, c)
+		}
 	}
-	var cols []string
-	for name := range tbl.Columns {
-		cols = append(cols, name)
+	if len(missing) > 0 {
+		return fmt.Errorf("custom query of table %s does not return key or compare columns: %s", table, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getRuleColumns 获取表结构、表的列和比对键，规则指定键列时优先使用
+func getRuleColumns(db conn.DBAdapter, rule ICompareRule) (*conn.Table, []string, []string, error) {
+	tbl, cols, pks, err := getTableColumns(db, rule.GetTable())
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	var pks []string
-	if tbl.PrimaryKey != nil {
-		pks = tbl.PrimaryKey.Columns
+	if keys := rule.GetKeyColumns(); len(keys) > 0 {
+		pks = keys
 	}
-	return cols, pks, nil
+	return tbl, cols, pks, nil
 }
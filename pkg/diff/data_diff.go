@@ -10,6 +10,74 @@ const (
 	DiffTypeModify DiffType = "MODIFY"
 )
 
+// DiffHandler 差异回调，changed 为修改的行中比较器判定不相等的列，返回错误时终止比对
+type DiffHandler func(diffType DiffType, srcRow, tgtRow conn.Record, changed []string) error
+
+func wrapHandler(handle func(diffType DiffType, srcRow, tgtRow conn.Record)) DiffHandler {
+	return func(diffType DiffType, srcRow, tgtRow conn.Record, _ []string) error {
+		handle(diffType, srcRow, tgtRow)
+		return nil
+	}
+}
+
+// sinkHandler 将差异写入 sink，实现 ChangeSink 时修改的行附带变化的列
+func sinkHandler(sink DiffSink) DiffHandler {
+	cs, ok := sink.(ChangeSink)
+	return func(diffType DiffType, srcRow, tgtRow conn.Record, changed []string) error {
+		if ok && diffType == DiffTypeModify {
+			return cs.WriteChange(srcRow, tgtRow, changed)
+		}
+		return sink.Write(diffType, srcRow, tgtRow)
+	}
+}
+
+// DiffSink 差异输出接口
+// 比对过程中逐行接收差异，由实现方决定缓冲、落盘方式，Close 时刷新剩余数据
+type DiffSink interface {
+	Write(diffType DiffType, srcRow, tgtRow conn.Record) error
+	Close() error
+}
+
+// ChangeSink 需要修改行变化列的 DiffSink，修改的行通过 WriteChange 写入，
+// changed 为比对规则的比较器判定不相等的列
+type ChangeSink interface {
+	DiffSink
+	WriteChange(srcRow, tgtRow conn.Record, changed []string) error
+}
+
+// DiffStats 差异行数统计
+type DiffStats struct {
+	Added    int64
+	Dropped  int64
+	Modified int64
+}
+
+func (s *DiffStats) Add(diffType DiffType) {
+	switch diffType {
+	case DiffTypeAdd:
+		s.Added++
+	case DiffTypeDrop:
+		s.Dropped++
+	case DiffTypeModify:
+		s.Modified++
+	}
+}
+
+func (s DiffStats) Total() int64 {
+	return s.Added + s.Dropped + s.Modified
+}
+
+// Write 实现 DiffSink，只统计差异行数，用于预览
+func (s *DiffStats) Write(diffType DiffType, srcRow, tgtRow conn.Record) error {
+	s.Add(diffType)
+	return nil
+}
+
+func (s *DiffStats) Close() error {
+	return nil
+}
+
+// DataDiff 在内存中汇总全部差异，仅适用于差异较少的场景
 type DataDiff struct {
 	Added    []conn.Record
 	Dropped  []conn.Record
@@ -19,4 +87,29 @@ type DataDiff struct {
 type ModifiedRow struct {
 	Old conn.Record
 	New conn.Record
+	// Changed 比较器判定不相等的列
+	Changed []string
+}
+
+// Write 实现 DiffSink，将差异行追加到 DataDiff 中
+func (d *DataDiff) Write(diffType DiffType, srcRow, tgtRow conn.Record) error {
+	switch diffType {
+	case DiffTypeAdd:
+		d.Added = append(d.Added, srcRow)
+	case DiffTypeDrop:
+		d.Dropped = append(d.Dropped, tgtRow)
+	case DiffTypeModify:
+		d.Modified = append(d.Modified, ModifiedRow{Old: tgtRow, New: srcRow})
+	}
+	return nil
+}
+
+// WriteChange 实现 ChangeSink，记录修改行变化的列
+func (d *DataDiff) WriteChange(srcRow, tgtRow conn.Record, changed []string) error {
+	d.Modified = append(d.Modified, ModifiedRow{Old: tgtRow, New: srcRow, Changed: changed})
+	return nil
+}
+
+func (d *DataDiff) Close() error {
+	return nil
 }
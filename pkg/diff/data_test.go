@@ -3,21 +3,28 @@ package diff
 import (
 	"database/sql"
 	"fmt"
+	"hash/fnv"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jacktea/data-smith/pkg/config"
 	"github.com/jacktea/data-smith/pkg/conn"
 )
 
 type mockDB struct {
-	rows []conn.Record
-	cols []string
-	pk   []string
+	rows  []conn.Record
+	cols  []string
+	pk    []string
+	types map[string]string
+
+	fetched int              // GetTableDataBatch 返回的总行数
+	filter  *conn.DataFilter // 最近一次 GetTableDataBatch 收到的过滤条件
 }
 
-func (m *mockDB) ReadSchema() (*conn.DatabaseSchema, error) {
+func (m *mockDB) ReadSchema(filter *config.SchemaFilter) (*conn.DatabaseSchema, error) {
 	tbl := &conn.Table{Columns: map[string]*conn.Column{}}
 	for _, c := range m.cols {
 		tbl.Columns[c] = &conn.Column{Name: c}
@@ -28,19 +35,19 @@ func (m *mockDB) ReadSchema() (*conn.DatabaseSchema, error) {
 
 func (m *mockDB) Close() error { return nil }
 
-func (m *mockDB) GetTableDataBatch(table string, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
+func (m *mockDB) GetTableDataBatch(table *conn.Table, filter *conn.DataFilter, cols, pk []string, lastPK []any, limit int) ([]conn.Record, error) {
+	m.filter = filter
 	start := 0
-	if lastPK != nil && len(lastPK) > 0 {
+	if len(lastPK) > 0 {
+		cmp := newPKComparator(table, pk)
+		last := conn.Record{}
+		for i, k := range pk {
+			last[k] = lastPK[i]
+		}
+		start = len(m.rows)
 		for i, row := range m.rows {
-			match := true
-			for j, k := range pk {
-				if row[k] != lastPK[j] {
-					match = false
-					break
-				}
-			}
-			if match {
-				start = i + 1
+			if cmp.Compare(row, last) > 0 {
+				start = i
 				break
 			}
 		}
@@ -49,11 +56,81 @@ func (m *mockDB) GetTableDataBatch(table string, cols, pk []string, lastPK []any
 	if end > len(m.rows) {
 		end = len(m.rows)
 	}
+	m.fetched += end - start
 	return m.rows[start:end], nil
 }
 
+func (m *mockDB) GetTableDataPage(table *conn.Table, filter *conn.DataFilter, cols []string, offset, limit int) ([]conn.Record, error) {
+	m.filter = filter
+	rows := slices.Clone(m.rows)
+	cmp := newPKComparator(table, cols)
+	slices.SortStableFunc(rows, cmp.Compare)
+	start := min(offset, len(rows))
+	end := min(start+limit, len(rows))
+	m.fetched += end - start
+	return rows[start:end], nil
+}
+
+func (m *mockDB) GetPKRange(table *conn.Table, filter *conn.DataFilter, pk string) (any, any, error) {
+	if len(m.rows) == 0 {
+		return nil, nil, nil
+	}
+	return m.rows[0][pk], m.rows[len(m.rows)-1][pk], nil
+}
+
+func (m *mockDB) GetRangeChecksum(table *conn.Table, filter *conn.DataFilter, cols []string, pk string, lower, upper any) (*conn.Checksum, error) {
+	kind := table.GetColumn(pk).Kind()
+	h := fnv.New64a()
+	var count int64
+	for _, row := range m.rows {
+		if compareValue(kind, row[pk], lower) < 0 || compareValue(kind, row[pk], upper) >= 0 {
+			continue
+		}
+		count++
+		for _, c := range cols {
+			fmt.Fprintf(h, "%v|", row[c])
+		}
+	}
+	return &conn.Checksum{Count: count, Sum: fmt.Sprintf("%x", h.Sum64())}, nil
+}
+
+func (m *mockDB) GetTableStats(table *conn.Table, filter *conn.DataFilter, key string, cols []string) (*conn.TableStats, error) {
+	stats := &conn.TableStats{RowCount: int64(len(m.rows)), Columns: map[string]*conn.ColumnStats{}}
+	if key != "" && len(m.rows) > 0 {
+		stats.MinKey, stats.MaxKey = m.rows[0][key], m.rows[len(m.rows)-1][key]
+	}
+	for _, c := range cols {
+		cs := &conn.ColumnStats{}
+		distinct := map[string]bool{}
+		var sum int64
+		for _, row := range m.rows {
+			if row[c] == nil {
+				cs.NullCount++
+				continue
+			}
+			distinct[fmt.Sprint(row[c])] = true
+			if n, ok := row[c].(int); ok {
+				sum += int64(n)
+			}
+		}
+		cs.Distinct = int64(len(distinct))
+		if table.GetColumn(c).Kind() == conn.ColumnKindNumeric {
+			cs.Sum = sum
+		}
+		stats.Columns[c] = cs
+	}
+	return stats, nil
+}
+
 func (m *mockDB) ExtractTable(tableName string) (*conn.Table, error) {
-	return nil, nil
+	tbl := &conn.Table{Name: tableName, Columns: map[string]*conn.Column{}}
+	for _, c := range m.cols {
+		tbl.Columns[c] = &conn.Column{Name: c, DataType: m.types[c]}
+	}
+	if len(m.pk) > 0 {
+		tbl.PrimaryKey = &conn.PrimaryKey{Columns: m.pk}
+	}
+	return tbl, nil
 }
 
 func (m *mockDB) ExtractView(viewName string) (*conn.Table, error) {
@@ -233,3 +310,228 @@ func TestStreamCompareData_MultiPKMultiCompare(t *testing.T) {
 		}
 	}
 }
+
+func TestStreamCompareData_NumericPKOrder(t *testing.T) {
+	cols := []string{"id", "val"}
+	pk := []string{"id"}
+	rule := CreateCompareRule(&conn.Table{Name: "t"}, []string{"val"})
+
+	var srcRows, tgtRows []conn.Record
+	for i := 1; i <= 12; i++ {
+		srcRows = append(srcRows, conn.Record{"id": int64(i), "val": fmt.Sprintf("v%d", i)})
+		if i != 10 {
+			tgtRows = append(tgtRows, conn.Record{"id": int64(i), "val": fmt.Sprintf("v%d", i)})
+		}
+	}
+	tgtRows[8]["val"] = "changed"
+
+	src := &mockDB{rows: srcRows, cols: cols, pk: pk}
+	tgt := &mockDB{rows: tgtRows, cols: cols, pk: pk}
+	var got []string
+	err := StreamCompareData(src, tgt, rule, 3, func(diffType DiffType, srcRow, tgtRow conn.Record) {
+		row := srcRow
+		if row == nil {
+			row = tgtRow
+		}
+		got = append(got, fmt.Sprintf("%s:%v", diffType, row["id"]))
+	})
+	if err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	expect := []string{"MODIFY:9", "ADD:10"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestCompareValue(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Second)
+	tests := []struct {
+		name   string
+		kind   conn.ColumnKind
+		a, b   any
+		expect int
+	}{
+		{"int by value", conn.ColumnKindUnknown, int64(9), int64(10), -1},
+		{"mixed int widths", conn.ColumnKindUnknown, int32(10), int64(10), 0},
+		{"decimal bytes", conn.ColumnKindNumeric, []byte("9.50"), []byte("10.1"), -1},
+		{"decimal vs int", conn.ColumnKindNumeric, []byte("10.00"), int64(10), 0},
+		{"time values", conn.ColumnKindUnknown, t2, t1, 1},
+		{"time text vs time", conn.ColumnKindTime, "2024-01-01 00:00:00", t1, 0},
+		{"text byte order", conn.ColumnKindText, "Z", "a", -1},
+		{"text bytes vs string", conn.ColumnKindText, []byte("abc"), "abc", 0},
+		{"binary", conn.ColumnKindBinary, []byte{0x01, 0xff}, []byte{0x02}, -1},
+		{"uuid case", conn.ColumnKindUUID, "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11", []byte("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"), 0},
+		{"nil last", conn.ColumnKindNumeric, nil, int64(1), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareValue(tt.kind, tt.a, tt.b); got != tt.expect {
+				t.Errorf("compareValue(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestChecksumCompareData(t *testing.T) {
+	cols := []string{"id", "val"}
+	pk := []string{"id"}
+	types := map[string]string{"id": "bigint", "val": "text"}
+	rule := CreateCompareRule(&conn.Table{Name: "t"}, []string{"val"})
+
+	var srcRows, tgtRows []conn.Record
+	for i := 1; i <= 5000; i++ {
+		srcRows = append(srcRows, conn.Record{"id": int64(i), "val": fmt.Sprintf("v%d", i)})
+		switch i {
+		case 17:
+			// 目标端缺失
+		case 2500:
+			tgtRows = append(tgtRows, conn.Record{"id": int64(i), "val": "changed"})
+		default:
+			tgtRows = append(tgtRows, conn.Record{"id": int64(i), "val": fmt.Sprintf("v%d", i)})
+		}
+	}
+	tgtRows = append(tgtRows, conn.Record{"id": int64(6000), "val": "extra"})
+
+	src := &mockDB{rows: srcRows, cols: cols, pk: pk, types: types}
+	tgt := &mockDB{rows: tgtRows, cols: cols, pk: pk, types: types}
+	var got []string
+	opts := ChecksumOptions{BisectionFactor: 4, BisectionThreshold: 50, BatchSize: 20}
+	err := ChecksumCompareData(src, tgt, rule, opts, func(diffType DiffType, srcRow, tgtRow conn.Record) {
+		row := srcRow
+		if row == nil {
+			row = tgtRow
+		}
+		got = append(got, fmt.Sprintf("%s:%v", diffType, row["id"]))
+	})
+	if err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	expect := []string{"ADD:17", "MODIFY:2500", "DROP:6000"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+	if src.fetched >= len(srcRows)/10 {
+		t.Errorf("fetched %d source rows, expect only differing buckets to be fetched", src.fetched)
+	}
+}
+
+func TestStreamCompareData_RuleKeyColumnsAndFilter(t *testing.T) {
+	// 表没有主键，由规则指定键列
+	cols := []string{"code", "val"}
+	types := map[string]string{"code": "varchar", "val": "varchar"}
+	src := &mockDB{rows: []conn.Record{{"code": "a", "val": "1"}, {"code": "b", "val": "2"}}, cols: cols, types: types}
+	tgt := &mockDB{rows: []conn.Record{{"code": "b", "val": "3"}}, cols: cols, types: types}
+	rule, err := CreateCompareRuleFromConfig(&conn.Table{Name: "t"}, config.Rule{
+		Table:         "t",
+		ComparisonKey: []string{"val"},
+		Where:         "tenant_id = 42",
+		KeyColumns:    []string{"code"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = StreamCompareData(src, tgt, rule, 10, func(diffType DiffType, srcRow, tgtRow conn.Record) {
+		got = append(got, fmt.Sprintf("%s:%v", diffType, srcRow["code"]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"ADD:a", "MODIFY:b"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+	for _, db := range []*mockDB{src, tgt} {
+		if db.filter == nil || db.filter.Where != "tenant_id = 42" {
+			t.Errorf("filter not passed to adapter: %+v", db.filter)
+		}
+	}
+}
+
+func TestStreamCompareData_WithoutPrimaryKey(t *testing.T) {
+	cols := []string{"a", "b"}
+	types := map[string]string{"a": "int", "b": "varchar"}
+	// 重复行按多重集合比对：源端 (1,x) 两行、目标端一行，应新增一行
+	src := &mockDB{rows: []conn.Record{{"a": 2, "b": "y"}, {"a": 1, "b": "x"}, {"a": 1, "b": "x"}, {"a": 3, "b": nil}}, cols: cols, types: types}
+	tgt := &mockDB{rows: []conn.Record{{"a": 1, "b": "x"}, {"a": 3, "b": nil}, {"a": 4, "b": "z"}, {"a": 4, "b": "z"}}, cols: cols, types: types}
+	rule := CreateCompareRule(&conn.Table{Name: "t"}, cols)
+	var got []string
+	err := StreamCompareData(src, tgt, rule, 2, func(diffType DiffType, srcRow, tgtRow conn.Record) {
+		row := srcRow
+		if row == nil {
+			row = tgtRow
+		}
+		got = append(got, fmt.Sprintf("%s:%v/%v", diffType, row["a"], row["b"]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"ADD:1/x", "ADD:2/y", "DROP:4/z", "DROP:4/z"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestStreamCompareData_QueryMissingColumns(t *testing.T) {
+	cols := []string{"code", "val"}
+	types := map[string]string{"code": "varchar", "val": "varchar"}
+	// 查询结果缺少比对列 val
+	src := &mockDB{rows: []conn.Record{{"code": "a"}}, cols: cols, types: types}
+	tgt := &mockDB{rows: []conn.Record{{"code": "a"}}, cols: cols, types: types}
+	rule, err := CreateCompareRuleFromConfig(&conn.Table{Name: "t"}, config.Rule{
+		Table:         "t",
+		ComparisonKey: []string{"val"},
+		Query:         "SELECT code FROM t",
+		KeyColumns:    []string{"code"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = StreamCompareData(src, tgt, rule, 10, func(DiffType, conn.Record, conn.Record) {})
+	if err == nil || !strings.Contains(err.Error(), "val") {
+		t.Fatalf("expect missing column error, got %v", err)
+	}
+}
+
+func TestRowBatchIteratorEarlyClose(t *testing.T) {
+	db := &mockDB{cols: []string{"id"}, pk: []string{"id"}, types: map[string]string{"id": "int"}}
+	for i := 0; i < 100; i++ {
+		db.rows = append(db.rows, conn.Record{"id": i})
+	}
+	tbl, _ := db.ExtractTable("t")
+	it := newRowBatchIterator(db, tbl, nil, []string{"id"}, []string{"id"}, 1)
+	if batch, err := it.NextBatch(); err != nil || len(batch) != 1 {
+		t.Fatalf("unexpected first batch %v, %v", batch, err)
+	}
+	it.Close()
+	it.Close()
+	// 提前关闭后不应继续读取剩余的数据
+	if db.fetched >= len(db.rows) {
+		t.Errorf("fetched %d rows after early close", db.fetched)
+	}
+}
+
+func TestStreamCompareData_ChangedColumns(t *testing.T) {
+	cols := []string{"id", "name", "score", "memo"}
+	types := map[string]string{"id": "int", "name": "varchar", "score": "decimal", "memo": "text"}
+	src := &mockDB{cols: cols, pk: []string{"id"}, types: types, rows: []conn.Record{{"id": 1, "name": "Alice", "score": "1.50", "memo": "x"}}}
+	tgt := &mockDB{cols: cols, pk: []string{"id"}, types: types, rows: []conn.Record{{"id": 1, "name": "alice", "score": 2, "memo": "y"}}}
+	tbl, _ := tgt.ExtractTable("t")
+	// memo 不参与比对，name 忽略大小写，只有 score 变化
+	rule, err := CreateCompareRuleFromConfig(tbl, config.Rule{
+		Table:         "t",
+		IgnoreColumns: []string{"memo"},
+		Columns:       map[string]config.ColumnRule{"name": {IgnoreCase: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := StreamCompareDataToDiff(src, tgt, rule, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Modified) != 1 || !reflect.DeepEqual(d.Modified[0].Changed, []string{"score"}) {
+		t.Errorf("unexpected modified rows %+v", d.Modified)
+	}
+}
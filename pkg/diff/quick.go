@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+// QuickStatus 快速比对的结论
+type QuickStatus string
+
+const (
+	// QuickIdentical 行数、键范围与列统计均一致
+	QuickIdentical QuickStatus = "identical"
+	// QuickSuspicious 行数与键范围一致但列统计不同，可能存在修改的行
+	QuickSuspicious QuickStatus = "suspicious"
+	// QuickDifferent 行数或键范围不同，必然存在新增或删除的行
+	QuickDifferent QuickStatus = "different"
+)
+
+// QuickResult 单张表的快速比对结果
+type QuickResult struct {
+	Table   string
+	Status  QuickStatus
+	Src     *conn.TableStats
+	Tgt     *conn.TableStats
+	Reasons []string
+}
+
+// QuickCompareData 只比较两端的统计信息：行数、首个键列的最小/最大值以及 statsCols 的聚合值
+// 统计一致不代表数据完全一致，结论为 different 或 suspicious 的表再做完整比对
+func QuickCompareData(srcDB, tgtDB conn.DBAdapter, rule ICompareRule, statsCols []string) (*QuickResult, error) {
+	tbl, _, pks, err := getRuleColumns(tgtDB, rule)
+	if err != nil {
+		return nil, err
+	}
+	var key string
+	if len(pks) > 0 {
+		key = pks[0]
+	}
+	src, err := srcDB.GetTableStats(tbl, rule.GetFilter(), key, statsCols)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	tgt, err := tgtDB.GetTableStats(tbl, rule.GetFilter(), key, statsCols)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	result := &QuickResult{Table: rule.GetTable(), Status: QuickIdentical, Src: src, Tgt: tgt}
+	if src.RowCount != tgt.RowCount {
+		result.Status = QuickDifferent
+		result.Reasons = append(result.Reasons, fmt.Sprintf("row count %d != %d", src.RowCount, tgt.RowCount))
+	}
+	if key != "" {
+		cmp := columnComparator(tbl, key)
+		if !cmp.Equal(src.MinKey, tgt.MinKey) {
+			result.Status = QuickDifferent
+			result.Reasons = append(result.Reasons, fmt.Sprintf("min %s %v != %v", key, displayValue(src.MinKey), displayValue(tgt.MinKey)))
+		}
+		if !cmp.Equal(src.MaxKey, tgt.MaxKey) {
+			result.Status = QuickDifferent
+			result.Reasons = append(result.Reasons, fmt.Sprintf("max %s %v != %v", key, displayValue(src.MaxKey), displayValue(tgt.MaxKey)))
+		}
+	}
+	for _, c := range statsCols {
+		s, t := src.Columns[c], tgt.Columns[c]
+		var reasons []string
+		if s.NullCount != t.NullCount {
+			reasons = append(reasons, fmt.Sprintf("%s null count %d != %d", c, s.NullCount, t.NullCount))
+		}
+		if s.Distinct != t.Distinct {
+			reasons = append(reasons, fmt.Sprintf("%s distinct count %d != %d", c, s.Distinct, t.Distinct))
+		}
+		if !(NumericComparator{}).Equal(s.Sum, t.Sum) {
+			reasons = append(reasons, fmt.Sprintf("%s sum %v != %v", c, displayValue(s.Sum), displayValue(t.Sum)))
+		}
+		if len(reasons) > 0 && result.Status == QuickIdentical {
+			result.Status = QuickSuspicious
+		}
+		result.Reasons = append(result.Reasons, reasons...)
+	}
+	return result, nil
+}
+
+func columnComparator(tbl *conn.Table, name string) ValueComparator {
+	if col := tbl.GetColumn(name); col != nil {
+		return ColumnComparator(col)
+	}
+	return ExactComparator{}
+}
+
+func displayValue(v any) string {
+	if v == nil {
+		return "NULL"
+	}
+	return toString(v)
+}
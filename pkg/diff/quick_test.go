@@ -0,0 +1,36 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+func TestQuickCompareData(t *testing.T) {
+	cols := []string{"id", "amount", "name"}
+	types := map[string]string{"id": "int", "amount": "int", "name": "varchar"}
+	rows := []conn.Record{{"id": 1, "amount": 10, "name": "a"}, {"id": 2, "amount": 20, "name": "b"}, {"id": 3, "amount": 30, "name": nil}}
+	rule := CreateCompareRule(&conn.Table{Name: "t"}, []string{"amount", "name"})
+
+	tests := []struct {
+		name    string
+		tgtRows []conn.Record
+		expect  QuickStatus
+		reasons int
+	}{
+		{"identical", rows, QuickIdentical, 0},
+		{"modified", []conn.Record{rows[0], {"id": 2, "amount": 21, "name": "b"}, rows[2]}, QuickSuspicious, 1},
+		{"dropped", rows[:2], QuickDifferent, 5},
+	}
+	for _, tt := range tests {
+		src := &mockDB{rows: rows, cols: cols, pk: []string{"id"}, types: types}
+		tgt := &mockDB{rows: tt.tgtRows, cols: cols, pk: []string{"id"}, types: types}
+		result, err := QuickCompareData(src, tgt, rule, []string{"amount", "name"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Status != tt.expect || len(result.Reasons) != tt.reasons {
+			t.Errorf("%s: got %s %v, expect %s with %d reasons", tt.name, result.Status, result.Reasons, tt.expect, tt.reasons)
+		}
+	}
+}
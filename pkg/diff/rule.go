@@ -2,40 +2,114 @@ package diff
 
 import (
 	"fmt"
+	"slices"
 
+	"github.com/jacktea/data-smith/pkg/config"
 	"github.com/jacktea/data-smith/pkg/conn"
 )
 
 type ICompareRule interface {
 	IsEqual(a, b conn.Record) bool
+	// ChangedColumns 按比较器返回两行中不相等的列
+	ChangedColumns(a, b conn.Record) []string
 	GetTable() string
+	// GetColumns 参与比对的列
+	GetColumns() []string
+	// GetKeyColumns 比对与分页使用的键列，为空时使用表主键
+	GetKeyColumns() []string
+	// GetFilter 行过滤条件，为 nil 时比对全表
+	GetFilter() *conn.DataFilter
 }
 
 type AllFieldsEqualRule struct {
-	Table   string
-	Columns []string
+	Table      string
+	Columns    []string
+	KeyColumns []string
+	Filter     *conn.DataFilter
+	// Comparators 按列指定的比较器，未指定的列按 %v 文本比较
+	Comparators map[string]ValueComparator
 }
 
 func (r *AllFieldsEqualRule) IsEqual(a, b conn.Record) bool {
 	for _, c := range r.Columns {
-		if fmt.Sprintf("%v", a[c]) != fmt.Sprintf("%v", b[c]) {
+		if !r.equalColumn(c, a, b) {
 			return false
 		}
 	}
 	return true
 }
 
+func (r *AllFieldsEqualRule) ChangedColumns(a, b conn.Record) []string {
+	var changed []string
+	for _, c := range r.Columns {
+		if !r.equalColumn(c, a, b) {
+			changed = append(changed, c)
+		}
+	}
+	return changed
+}
+
+func (r *AllFieldsEqualRule) equalColumn(c string, a, b conn.Record) bool {
+	if cmp, ok := r.Comparators[c]; ok {
+		return cmp.Equal(a[c], b[c])
+	}
+	return fmt.Sprintf("%v", a[c]) == fmt.Sprintf("%v", b[c])
+}
+
+func (r *AllFieldsEqualRule) GetColumns() []string {
+	return r.Columns
+}
+
 func (r *AllFieldsEqualRule) GetTable() string {
 	return r.Table
 }
 
+func (r *AllFieldsEqualRule) GetKeyColumns() []string {
+	return r.KeyColumns
+}
+
+func (r *AllFieldsEqualRule) GetFilter() *conn.DataFilter {
+	return r.Filter
+}
+
 func CreateCompareRule(table *conn.Table, comparisonKey []string) ICompareRule {
 	cols := comparisonKey
 	if len(cols) == 0 {
 		cols = table.GetColumns()
 	}
+	comparators := map[string]ValueComparator{}
+	for _, c := range cols {
+		// 时间、JSON 等列按类型比较，避免跨数据库的文本表示差异
+		if col := table.GetColumn(c); col.Kind() != conn.ColumnKindUnknown {
+			comparators[c] = ColumnComparator(col)
+		}
+	}
 	return &AllFieldsEqualRule{
-		Table:   table.Name,
-		Columns: cols,
+		Table:       table.Name,
+		Columns:     cols,
+		Comparators: comparators,
+	}
+}
+
+// CreateCompareRuleFromConfig 根据配置规则创建比对规则，包含行过滤条件、键列、忽略列与列比较方式
+func CreateCompareRuleFromConfig(table *conn.Table, rule config.Rule) (ICompareRule, error) {
+	r := CreateCompareRule(table, rule.ComparisonKey).(*AllFieldsEqualRule)
+	r.KeyColumns = rule.KeyColumns
+	filter := &conn.DataFilter{Where: rule.Where, Query: rule.Query}
+	if !filter.IsEmpty() {
+		r.Filter = filter
+	}
+	if len(rule.IgnoreColumns) > 0 {
+		r.Columns = slices.DeleteFunc(slices.Clone(r.Columns), func(c string) bool {
+			return slices.Contains(rule.IgnoreColumns, c)
+		})
+	}
+	for name, colRule := range rule.Columns {
+		cmp, err := NewColumnComparator(table.GetColumn(name), colRule)
+		if err != nil {
+			return nil, fmt.Errorf("column %s.%s: %w", table.Name, name, err)
+		}
+		r.Comparators[name] = cmp
 	}
+	return r, nil
 }
@@ -1,28 +1,31 @@
 package diff
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/jacktea/data-smith/pkg/config"
 	"github.com/jacktea/data-smith/pkg/conn"
 )
 
-func CompareSchemasWithAdapter(src, tgt conn.DBAdapter) (*SchemaDiff, error) {
-	srcSchema, err := src.ReadSchema()
+// CompareSchemasWithAdapter 读取并比对两端结构，filter 同时用于读取与比对，为 nil 时比对全部对象
+func CompareSchemasWithAdapter(src, tgt conn.DBAdapter, filter *config.SchemaFilter) (*SchemaDiff, error) {
+	srcSchema, err := src.ReadSchema(filter)
 	if err != nil {
 		return nil, err
 	}
-	tgtSchema, err := tgt.ReadSchema()
+	tgtSchema, err := tgt.ReadSchema(filter)
 	if err != nil {
 		return nil, err
 	}
-	return CompareSchemas(srcSchema, tgtSchema), nil
+	return CompareSchemas(srcSchema, tgtSchema, filter), nil
 }
 
-func CompareSchemas(src, tgt *conn.DatabaseSchema) *SchemaDiff {
+func CompareSchemas(src, tgt *conn.DatabaseSchema, filter *config.SchemaFilter) *SchemaDiff {
 	diff := &SchemaDiff{}
 	// 表级
-	srcTables := src.Tables
-	tgtTables := tgt.Tables
+	srcTables := filterTables(src.Tables, filter)
+	tgtTables := filterTables(tgt.Tables, filter)
 	srcTableSet := map[string]struct{}{}
 	tgtTableSet := map[string]struct{}{}
 	for name := range srcTables {
@@ -49,7 +52,7 @@ func CompareSchemas(src, tgt *conn.DatabaseSchema) *SchemaDiff {
 		if !ok {
 			continue
 		}
-		tblDiff := compareTable(srcTbl, tgtTbl)
+		tblDiff := compareTable(srcTbl, tgtTbl, filter)
 		if tblDiff != nil {
 			diff.TablesModified = append(diff.TablesModified, tblDiff)
 		}
@@ -57,7 +60,24 @@ func CompareSchemas(src, tgt *conn.DatabaseSchema) *SchemaDiff {
 	return diff
 }
 
-func compareTable(src, tgt *conn.Table) *TableDiff {
+// filterTables 返回匹配过滤条件的表
+func filterTables(tables map[string]*conn.Table, filter *config.SchemaFilter) map[string]*conn.Table {
+	if filter == nil {
+		return tables
+	}
+	filtered := make(map[string]*conn.Table, len(tables))
+	for name, tbl := range tables {
+		if filter.Match(name, string(tbl.Type)) {
+			filtered[name] = tbl
+		}
+	}
+	return filtered
+}
+
+func compareTable(src, tgt *conn.Table, filter *config.SchemaFilter) *TableDiff {
+	if filter == nil {
+		filter = &config.SchemaFilter{}
+	}
 	if src.Type != tgt.Type {
 		return nil
 	}
@@ -92,13 +112,17 @@ func compareTable(src, tgt *conn.Table) *TableDiff {
 	}
 	for name, srcCol := range srcCols {
 		tgtCol, ok := tgtCols[name]
-		if ok && !equalColumn(srcCol, tgtCol) {
+		if ok && !equalColumn(srcCol, tgtCol, filter) {
 			d.ColumnsModified = append(d.ColumnsModified, &ColumnDiff{Old: tgtCol, New: srcCol})
 		}
 	}
-	// 索引
+	// 索引，忽略索引名时按定义匹配
 	srcIdx := src.Indexes
 	tgtIdx := tgt.Indexes
+	if filter.IgnoreIndexNames {
+		srcIdx = indexesByDefinition(srcIdx)
+		tgtIdx = indexesByDefinition(tgtIdx)
+	}
 	for name, idx := range srcIdx {
 		if _, ok := tgtIdx[name]; !ok {
 			d.IndexesAdded = append(d.IndexesAdded, idx)
@@ -111,12 +135,12 @@ func compareTable(src, tgt *conn.Table) *TableDiff {
 	}
 	for name, srcI := range srcIdx {
 		tgtI, ok := tgtIdx[name]
-		if ok && !equalIndex(srcI, tgtI) {
+		if ok && !filter.IgnoreIndexNames && !equalIndex(srcI, tgtI) {
 			d.IndexesModified = append(d.IndexesModified, &IndexDiff{Old: tgtI, New: srcI})
 		}
 	}
 	// 主键
-	if !equalPrimaryKey(src.PrimaryKey, tgt.PrimaryKey) {
+	if !equalPrimaryKey(src.PrimaryKey, tgt.PrimaryKey, filter.IgnoreIndexNames) {
 		d.PrimaryKeyChange = &PrimaryKeyDiff{Old: tgt.PrimaryKey, New: src.PrimaryKey}
 	}
 	// 外键
@@ -144,7 +168,24 @@ func compareTable(src, tgt *conn.Table) *TableDiff {
 	return nil
 }
 
-func equalColumn(a, b *conn.Column) bool {
+// indexesByDefinition 以索引定义(唯一性、方法、列、条件、表达式)为 key 重建索引集合
+func indexesByDefinition(indexes map[string]*conn.Index) map[string]*conn.Index {
+	byDef := make(map[string]*conn.Index, len(indexes))
+	for _, idx := range indexes {
+		key := fmt.Sprintf("%t|%t|%s|%s|%s|%s", idx.Unique, idx.Primary, idx.Method, strings.Join(idx.Columns, ","), ptrString(idx.Where), ptrString(idx.Expression))
+		byDef[key] = idx
+	}
+	return byDef
+}
+
+func ptrString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func equalColumn(a, b *conn.Column, filter *config.SchemaFilter) bool {
 	if a == nil || b == nil {
 		return a == b
 	}
@@ -153,7 +194,9 @@ func equalColumn(a, b *conn.Column) bool {
 		return false
 	}
 	// 比较Default值
-	if a.Default == nil && b.Default == nil {
+	if filter.IgnoreDefaults {
+		// 忽略默认值
+	} else if a.Default == nil && b.Default == nil {
 		// 都为nil，相等
 	} else if a.Default == nil || b.Default == nil {
 		// 一个为nil，一个不为nil，不相等
@@ -163,7 +206,7 @@ func equalColumn(a, b *conn.Column) bool {
 		return false
 	}
 	// 比较Comment
-	if !equalComment(a.Comment, b.Comment) {
+	if !filter.IgnoreComments && !equalComment(a.Comment, b.Comment) {
 		return false
 	}
 	// 比较CharMaxLen
@@ -239,11 +282,11 @@ func equalIndex(a, b *conn.Index) bool {
 	return true
 }
 
-func equalPrimaryKey(a, b *conn.PrimaryKey) bool {
+func equalPrimaryKey(a, b *conn.PrimaryKey, ignoreName bool) bool {
 	if a == nil || b == nil {
 		return a == b
 	}
-	if a.Name != b.Name {
+	if !ignoreName && a.Name != b.Name {
 		return false
 	}
 	if len(a.Columns) != len(b.Columns) {
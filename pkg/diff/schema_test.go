@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+func TestCompareSchemasWithFilter(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	newTable := func(name string, comment, def string, idxName string) *conn.Table {
+		return &conn.Table{
+			Name: name,
+			Type: conn.TableTypeTable,
+			Columns: map[string]*conn.Column{
+				"id":   {Name: "id", DataType: "int"},
+				"name": {Name: "name", DataType: "varchar", Nullable: true, Comment: strPtr(comment), Default: strPtr(def)},
+			},
+			Indexes: map[string]*conn.Index{
+				idxName: {Name: idxName, Columns: []string{"name"}},
+			},
+			PrimaryKey: &conn.PrimaryKey{Name: name + "_pk", Columns: []string{"id"}},
+		}
+	}
+	src := &conn.DatabaseSchema{Tables: map[string]*conn.Table{
+		"app_user":          newTable("app_user", "user name", "'a'", "idx_name"),
+		"app_user_bak":      newTable("app_user_bak", "", "", "idx_bak"),
+		"schema_migrations": newTable("schema_migrations", "", "", "idx_m"),
+	}}
+	tgt := &conn.DatabaseSchema{Tables: map[string]*conn.Table{
+		"app_user": newTable("app_user", "name", "'b'", "app_user_name_idx"),
+	}}
+
+	d := CompareSchemas(src, tgt, nil)
+	if len(d.TablesAdded) != 2 || len(d.TablesModified) != 1 {
+		t.Fatalf("unexpected diff without filter: %+v", d)
+	}
+	if td := d.TablesModified[0]; len(td.ColumnsModified) != 1 || len(td.IndexesAdded) != 1 || len(td.IndexesDropped) != 1 {
+		t.Fatalf("unexpected table diff without filter: %+v", td)
+	}
+
+	filter := &config.SchemaFilter{Include: []string{"app_*"}, Exclude: []string{"*_bak"}}
+	d = CompareSchemas(src, tgt, filter)
+	if len(d.TablesAdded) != 0 || len(d.TablesModified) != 1 {
+		t.Fatalf("unexpected diff with filter: %+v", d)
+	}
+
+	filter.IgnoreComments = true
+	filter.IgnoreDefaults = true
+	filter.IgnoreIndexNames = true
+	if d = CompareSchemas(src, tgt, filter); len(d.TablesModified) != 0 {
+		t.Errorf("expect no differences when ignoring comments, defaults and index names: %+v", d.TablesModified[0])
+	}
+
+	if d = CompareSchemas(src, tgt, &config.SchemaFilter{Exclude: []string{`re:^(app_user_bak|schema_.*)$`}}); len(d.TablesAdded) != 0 {
+		t.Errorf("regex exclude not applied: %+v", d.TablesAdded)
+	}
+	if d = CompareSchemas(src, tgt, &config.SchemaFilter{Types: []string{"view"}}); len(d.TablesAdded)+len(d.TablesModified) != 0 {
+		t.Errorf("type filter not applied: %+v", d)
+	}
+}
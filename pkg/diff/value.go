@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+)
+
+// pkComparator 按主键列的真实类型比较两行记录
+// 排序语义需与 GetTableDataBatch 的 ORDER BY 保持一致：
+// 数值按大小、时间按先后、文本与二进制按字节序(数据库端使用 C/binary 排序规则)
+type pkComparator struct {
+	pk    []string
+	kinds []conn.ColumnKind
+}
+
+func newPKComparator(tbl *conn.Table, pk []string) *pkComparator {
+	kinds := make([]conn.ColumnKind, len(pk))
+	for i, k := range pk {
+		if tbl != nil {
+			kinds[i] = tbl.GetColumn(k).Kind()
+		}
+	}
+	return &pkComparator{pk: pk, kinds: kinds}
+}
+
+// Compare 比较主键值，返回 -1/0/1
+func (c *pkComparator) Compare(a, b conn.Record) int {
+	for i, k := range c.pk {
+		if r := compareValue(c.kinds[i], a[k], b[k]); r != 0 {
+			return r
+		}
+	}
+	return 0
+}
+
+// compareValue 按列类别比较两个值，NULL 视为最大(与 PostgreSQL 默认的 NULLS LAST 一致)
+func compareValue(kind conn.ColumnKind, a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+	if kind == conn.ColumnKindUnknown {
+		kind = inferKind(a, b)
+	}
+	switch kind {
+	case conn.ColumnKindNumeric, conn.ColumnKindBool:
+		ar, aok := toRat(a)
+		br, bok := toRat(b)
+		if aok && bok {
+			return ar.Cmp(br)
+		}
+	case conn.ColumnKindTime:
+		at, aok := toTime(a)
+		bt, bok := toTime(b)
+		if aok && bok {
+			return at.Compare(bt)
+		}
+	case conn.ColumnKindUUID:
+		return strings.Compare(normalizeUUID(toString(a)), normalizeUUID(toString(b)))
+	}
+	return bytes.Compare(toBytes(a), toBytes(b))
+}
+
+// inferKind 在缺少列类型信息时根据 Go 值类型推断类别
+func inferKind(a, b any) conn.ColumnKind {
+	if isNumber(a) && isNumber(b) {
+		return conn.ColumnKindNumeric
+	}
+	if _, ok := a.(time.Time); ok {
+		if _, ok := b.(time.Time); ok {
+			return conn.ColumnKindTime
+		}
+	}
+	return conn.ColumnKindUnknown
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+// toRat 将数值(驱动可能返回整型、浮点或 []byte 形式的 decimal)转换为精确有理数
+func toRat(v any) (*big.Rat, bool) {
+	r := new(big.Rat)
+	switch n := v.(type) {
+	case int:
+		return r.SetInt64(int64(n)), true
+	case int8:
+		return r.SetInt64(int64(n)), true
+	case int16:
+		return r.SetInt64(int64(n)), true
+	case int32:
+		return r.SetInt64(int64(n)), true
+	case int64:
+		return r.SetInt64(n), true
+	case uint:
+		return r.SetUint64(uint64(n)), true
+	case uint8:
+		return r.SetUint64(uint64(n)), true
+	case uint16:
+		return r.SetUint64(uint64(n)), true
+	case uint32:
+		return r.SetUint64(uint64(n)), true
+	case uint64:
+		return r.SetUint64(n), true
+	case float32:
+		if r.SetFloat64(float64(n)) == nil {
+			return nil, false
+		}
+		return r, true
+	case float64:
+		if r.SetFloat64(n) == nil {
+			return nil, false
+		}
+		return r, true
+	case bool:
+		if n {
+			return r.SetInt64(1), true
+		}
+		return r.SetInt64(0), true
+	case []byte, string:
+		s := strings.TrimSpace(toString(n))
+		switch strings.ToLower(s) {
+		case "t", "true":
+			return r.SetInt64(1), true
+		case "f", "false":
+			return r.SetInt64(0), true
+		}
+		if _, ok := r.SetString(s); ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case []byte, string:
+		s := strings.TrimSpace(toString(t))
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func toString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func toBytes(v any) []byte {
+	switch s := v.(type) {
+	case []byte:
+		return s
+	case string:
+		return []byte(s)
+	default:
+		return []byte(fmt.Sprintf("%v", v))
+	}
+}
+
+func normalizeUUID(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "-", ""))
+}
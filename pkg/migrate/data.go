@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/sql"
+)
+
+const (
+	DataOpInsert = "insert"
+	DataOpUpsert = "upsert"
+	DataOpDelete = "delete"
+	DataOpUpdate = "update"
+)
+
+// DataMigration JSON 格式的数据迁移脚本，按目标库方言生成语句，
+// 同一脚本可用于 MySQL 与 PostgreSQL
+type DataMigration struct {
+	Operations []*DataOperation `json:"operations"`
+}
+
+// DataOperation 对一张表的数据变更
+type DataOperation struct {
+	// Op insert/upsert/delete/update
+	Op    string `json:"op"`
+	Table string `json:"table"`
+	// Key 定位行的列，为空时使用表的主键或唯一索引
+	Key []string `json:"key"`
+	// Rows insert/upsert 写入的行；delete 时为待删除行的键值
+	Rows []map[string]any `json:"rows"`
+	// UpdateColumns upsert 键冲突时更新的列，为空时更新行中除键以外的全部列
+	UpdateColumns []string `json:"updateColumns"`
+	// Set update 更新的列值
+	Set map[string]any `json:"set"`
+	// Where update 的等值条件，值为 null 时匹配 IS NULL
+	Where map[string]any `json:"where"`
+}
+
+// ParseDataMigration 解析 JSON 数据迁移脚本，数值保持原始文本避免精度丢失
+func ParseDataMigration(content string) (*DataMigration, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(content)))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	var m DataMigration
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("解析数据迁移脚本失败: %w", err)
+	}
+	return &m, nil
+}
+
+// RenderDataMigration 读取目标表结构并通过目标库方言生成数据迁移语句
+func RenderDataMigration(db conn.DBAdapter, content string) ([]string, error) {
+	m, err := ParseDataMigration(content)
+	if err != nil {
+		return nil, err
+	}
+	dialect := sql.NewDialect(db.GetConfig().Type)
+	if dialect == nil {
+		return nil, fmt.Errorf("不支持的数据库类型: %s", db.GetConfig().Type)
+	}
+	tables := map[string]*conn.Table{}
+	return m.Render(dialect, func(name string) (*conn.Table, error) {
+		if t, ok := tables[name]; ok {
+			return t, nil
+		}
+		t, err := db.ExtractTable(name)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil || len(t.Columns) == 0 {
+			return nil, fmt.Errorf("表 %s 不存在", name)
+		}
+		tables[name] = t
+		return t, nil
+	})
+}
+
+// Render 依次生成每项变更的语句，table 返回表结构
+func (m *DataMigration) Render(dialect sql.IDialect, table func(name string) (*conn.Table, error)) ([]string, error) {
+	var stmts []string
+	for i, op := range m.Operations {
+		tbl, err := table(op.Table)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 项变更: %w", i+1, err)
+		}
+		s, err := op.render(dialect, tbl)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 项变更(%s %s): %w", i+1, op.Op, op.Table, err)
+		}
+		stmts = append(stmts, s...)
+	}
+	return stmts, nil
+}
+
+func (op *DataOperation) render(dialect sql.IDialect, tbl *conn.Table) ([]string, error) {
+	key := op.Key
+	if len(key) == 0 {
+		key = tbl.GetRowKeyColumns()
+	}
+	var stmts []string
+	switch op.Op {
+	case DataOpInsert:
+		for _, row := range op.Rows {
+			sub, err := subTable(tbl, row, nil)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, dialect.GenerateInsertSql(sub, record(row)))
+		}
+	case DataOpUpsert:
+		if len(key) == 0 {
+			return nil, fmt.Errorf("表没有主键或唯一索引，需要指定 key")
+		}
+		for _, row := range op.Rows {
+			if err := requireColumns(row, key); err != nil {
+				return nil, err
+			}
+			sub, err := subTable(tbl, row, key)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, dialect.GenerateUpsertSql(sub, record(row), op.UpdateColumns))
+		}
+	case DataOpDelete:
+		if len(key) == 0 {
+			return nil, fmt.Errorf("表没有主键或唯一索引，需要指定 key")
+		}
+		for _, row := range op.Rows {
+			if err := requireColumns(row, key); err != nil {
+				return nil, err
+			}
+			keyRow := map[string]any{}
+			for _, k := range key {
+				keyRow[k] = row[k]
+			}
+			sub, err := subTable(tbl, keyRow, key)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, dialect.GenerateDeleteSql(sub, record(keyRow)))
+		}
+	case DataOpUpdate:
+		if len(op.Set) == 0 || len(op.Where) == 0 {
+			return nil, fmt.Errorf("update 需要指定 set 与 where")
+		}
+		row := map[string]any{}
+		var setCols, whereCols []string
+		for c, v := range op.Set {
+			row[c] = v
+			setCols = append(setCols, c)
+		}
+		for c, v := range op.Where {
+			if _, ok := op.Set[c]; ok {
+				return nil, fmt.Errorf("列 %s 不能同时出现在 set 与 where 中", c)
+			}
+			row[c] = v
+			whereCols = append(whereCols, c)
+		}
+		sort.Strings(setCols)
+		sort.Strings(whereCols)
+		sub, err := subTable(tbl, row, whereCols)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, dialect.GenerateUpdateSql(sub, record(row), setCols))
+	default:
+		return nil, fmt.Errorf("不支持的操作 %q", op.Op)
+	}
+	return stmts, nil
+}
+
+// subTable 只包含行中出现的列的表结构，key 作为行键，使方言只为这些列生成语句
+func subTable(tbl *conn.Table, row map[string]any, key []string) (*conn.Table, error) {
+	sub := &conn.Table{
+		Name:    tbl.Name,
+		Type:    tbl.Type,
+		Schema:  tbl.Schema,
+		Columns: map[string]*conn.Column{},
+	}
+	for c := range row {
+		col := tbl.GetColumn(c)
+		if col == nil {
+			return nil, fmt.Errorf("表 %s 没有列 %s", tbl.Name, c)
+		}
+		sub.Columns[c] = col
+	}
+	if len(key) > 0 {
+		sub.PrimaryKey = &conn.PrimaryKey{Columns: key}
+	}
+	return sub, nil
+}
+
+func requireColumns(row map[string]any, cols []string) error {
+	for _, c := range cols {
+		if _, ok := row[c]; !ok {
+			return fmt.Errorf("行 %v 缺少键列 %s", row, c)
+		}
+	}
+	return nil
+}
+
+// record 转换为方言使用的行数据，对象与数组序列化为 JSON 文本
+func record(row map[string]any) conn.Record {
+	r := conn.Record{}
+	for c, v := range row {
+		switch v.(type) {
+		case map[string]any, []any:
+			b, _ := json.Marshal(v)
+			r[c] = string(b)
+		default:
+			r[c] = v
+		}
+	}
+	return r
+}
@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/jacktea/data-smith/pkg/sql"
+)
+
+func dictTable(name string) (*conn.Table, error) {
+	if name != "dict" {
+		return nil, errors.New("表 " + name + " 不存在")
+	}
+	return &conn.Table{
+		Name: "dict",
+		Columns: map[string]*conn.Column{
+			"id":    {Name: "id", DataType: "integer", Position: 1},
+			"code":  {Name: "code", DataType: "varchar", Position: 2},
+			"name":  {Name: "name", DataType: "varchar", Position: 3},
+			"extra": {Name: "extra", DataType: "jsonb", Position: 4, Nullable: true},
+		},
+		PrimaryKey: &conn.PrimaryKey{Name: "dict_pkey", Columns: []string{"id"}},
+	}, nil
+}
+
+func TestRenderDataMigration(t *testing.T) {
+	m, err := ParseDataMigration(`{"operations": [
+		{"op": "upsert", "table": "dict", "key": ["code"], "rows": [{"code": "A", "name": "O'Neil", "extra": {"k": 1}}]},
+		{"op": "insert", "table": "dict", "rows": [{"id": 12345678901234567890, "code": "B"}]},
+		{"op": "delete", "table": "dict", "rows": [{"id": 3, "name": "ignored"}]},
+		{"op": "update", "table": "dict", "set": {"name": "new"}, "where": {"code": "C", "extra": null}}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := m.Render(sql.NewDialect(consts.DBTypePostgres), dictTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		`INSERT INTO dict ("code", "name", "extra") VALUES ('A', 'O''Neil', '{"k":1}') ON CONFLICT ("code") DO UPDATE SET "name" = EXCLUDED."name", "extra" = EXCLUDED."extra";`,
+		`INSERT INTO dict ("id", "code") VALUES (12345678901234567890, 'B');`,
+		`DELETE FROM dict WHERE "id" = 3;`,
+		`UPDATE dict SET "name" = 'new' WHERE "code" = 'C' AND "extra" IS NULL;`,
+	}
+	if !reflect.DeepEqual(stmts, expect) {
+		t.Errorf("got\n%v\nexpect\n%v", stmts, expect)
+	}
+
+	stmts, err = m.Render(sql.NewDialect(consts.DBTypeMySQL), dictTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts[0] != "INSERT INTO `dict` (`code`, `name`, `extra`) VALUES ('A', 'O''Neil', '{\"k\":1}') ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `extra` = VALUES(`extra`);" {
+		t.Errorf("unexpected mysql upsert %s", stmts[0])
+	}
+}
+
+func TestRenderDataMigrationErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown table":  `{"operations": [{"op": "insert", "table": "missing", "rows": [{"id": 1}]}]}`,
+		"unknown column": `{"operations": [{"op": "insert", "table": "dict", "rows": [{"nope": 1}]}]}`,
+		"missing key":    `{"operations": [{"op": "delete", "table": "dict", "rows": [{"code": "A"}]}]}`,
+		"set and where":  `{"operations": [{"op": "update", "table": "dict", "set": {"code": "B"}, "where": {"code": "A"}}]}`,
+		"unknown op":     `{"operations": [{"op": "truncate", "table": "dict"}]}`,
+	}
+	for name, content := range cases {
+		m, err := ParseDataMigration(content)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := m.Render(sql.NewDialect(consts.DBTypePostgres), dictTable); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+	if _, err := ParseDataMigration(`{"operations": [{"op": "insert", "tabel": "dict"}]}`); err == nil {
+		t.Errorf("expect error for unknown field")
+	}
+}
+
+// 字符串值按 Go 类型加引号：引号、反斜杠、换行不被破坏，文本子类型与数值列中的文本不被直接拼接
+func TestRenderDataMigrationEscaping(t *testing.T) {
+	table := func(string) (*conn.Table, error) {
+		return &conn.Table{
+			Name: "note",
+			Columns: map[string]*conn.Column{
+				"id":   {Name: "id", DataType: "bigint", Position: 1},
+				"body": {Name: "body", DataType: "longtext", Position: 2},
+				"memo": {Name: "memo", DataType: "tinytext", Position: 3},
+			},
+			PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+		}, nil
+	}
+	m, err := ParseDataMigration(`{"operations": [
+		{"op": "insert", "table": "note", "rows": [{"id": "1; DROP TABLE note", "body": "it's C:\\dir\nnext", "memo": "plain"}]}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[consts.DBType]string{
+		consts.DBTypeMySQL:    "INSERT INTO `note` (`id`, `body`, `memo`) VALUES ('1; DROP TABLE note', 'it''s C:\\\\dir\\nnext', 'plain');",
+		consts.DBTypePostgres: `INSERT INTO note ("id", "body", "memo") VALUES ('1; DROP TABLE note', E'it''s C:\\dir\nnext', 'plain');`,
+	}
+	for dbType, want := range expect {
+		stmts, err := m.Render(sql.NewDialect(dbType), table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmts[0] != want {
+			t.Errorf("%s: got\n%s\nexpect\n%s", dbType, stmts[0], want)
+		}
+	}
+}
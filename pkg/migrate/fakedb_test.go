@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+// fakeDriver 记录收到的语句，用于在没有数据库的情况下检查执行了哪些语句
+type fakeDriver struct {
+	mu   sync.Mutex
+	log  []string
+	fail string // 包含该文本的语句执行失败
+}
+
+func (d *fakeDriver) record(kind, query string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, kind+" "+query)
+	if d.fail != "" && strings.Contains(query, d.fail) {
+		return errors.New("fake failure")
+	}
+	return nil
+}
+
+func (d *fakeDriver) statements() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.log...)
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if err := c.d.record("BEGIN", ""); err != nil {
+		return nil, err
+	}
+	return &fakeTx{d: c.d}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.record("EXEC", query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.record("QUERY", query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	return t.d.record("COMMIT", "")
+}
+
+func (t *fakeTx) Rollback() error {
+	return t.d.record("ROLLBACK", "")
+}
+
+// fakeRows 查询总是返回空结果
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string {
+	return nil
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+var fakeDriverSeq atomic.Int64
+
+// fakeAdapter 只实现迁移用到的 GetConn 与 GetConfig
+type fakeAdapter struct {
+	conn.DBAdapter
+	db  *sql.DB
+	cfg *config.ConnConfig
+}
+
+func (a *fakeAdapter) GetConn() *sql.DB {
+	return a.db
+}
+
+func (a *fakeAdapter) GetConfig() *config.ConnConfig {
+	return a.cfg
+}
+
+func newFakeAdapter(dbType consts.DBType) (*fakeAdapter, *fakeDriver) {
+	d := &fakeDriver{}
+	name := fmt.Sprintf("migrate-fake-%d", fakeDriverSeq.Add(1))
+	sql.Register(name, d)
+	db, _ := sql.Open(name, "")
+	return &fakeAdapter{db: db, cfg: &config.ConnConfig{Type: dbType, DBName: "app", TableSchema: "public"}}, d
+}
@@ -0,0 +1,151 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/logger"
+)
+
+var (
+	// ErrLockTimeout 在超时时间内未能获取迁移锁
+	ErrLockTimeout = errors.New("获取迁移锁超时")
+	// ErrAdvisoryLockUnsupported 数据库不支持咨询锁(如 Galera 严格模式、不提供锁函数的兼容库)
+	ErrAdvisoryLockUnsupported = errors.New("数据库不支持咨询锁")
+)
+
+const (
+	// LockAdvisory 使用数据库的咨询锁，不可用时改用锁表
+	LockAdvisory = "advisory"
+	// LockTable 使用 schema_migrations_lock 表
+	LockTable = "table"
+
+	// DefaultLockTimeout 默认的等待迁移锁的时间
+	DefaultLockTimeout = 5 * time.Minute
+
+	lockRetryInterval = time.Second
+)
+
+// Lock 已获取的迁移锁
+type Lock struct {
+	release func() error
+}
+
+// Release 释放迁移锁
+func (l *Lock) Release() error {
+	return l.release()
+}
+
+// AcquireLock 获取迁移锁，保证同一时间只有一个进程执行迁移。
+// 咨询锁是会话级的，获取后独占一个连接直到释放
+func AcquireLock(db conn.DBAdapter, mode string, timeout time.Duration) (*Lock, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	switch mode {
+	case "", LockAdvisory:
+	case LockTable:
+		return tableLock(store, timeout)
+	default:
+		return nil, fmt.Errorf("不支持的迁移锁: %s", mode)
+	}
+
+	ctx := context.Background()
+	c, err := db.GetConn().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := store.AdvisoryLock(ctx, c, timeout)
+	if err != nil {
+		c.Close()
+		// 只有确认不支持咨询锁时才改用锁表，连接中断等错误直接返回
+		if !errors.Is(err, ErrAdvisoryLockUnsupported) {
+			return nil, err
+		}
+		logger.Warnf("%v，改用锁表", err)
+		return tableLock(store, timeout)
+	}
+	if !ok {
+		c.Close()
+		return nil, fmt.Errorf("%w: %s 内未能获取迁移锁，可能有其他进程正在执行迁移", ErrLockTimeout, timeout)
+	}
+	return &Lock{release: func() error {
+		defer c.Close()
+		return store.AdvisoryUnlock(ctx, c)
+	}}, nil
+}
+
+func tableLock(store MigrationStore, timeout time.Duration) (*Lock, error) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	if err := store.EnsureLockTable(); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		// 插入失败说明锁已被持有(主键冲突)
+		err := store.TryTableLock(owner)
+		if err == nil {
+			return &Lock{release: store.TableUnlock}, nil
+		}
+		if time.Now().After(deadline) {
+			holder, lockedAt := store.TableLockHolder()
+			return nil, fmt.Errorf("%w: %s 内未能获取迁移锁，锁由 %s 于 %s 持有；确认该进程已退出后可使用 --force-unlock 删除锁记录",
+				ErrLockTimeout, timeout, holder, lockedAt.Format("2006-01-02 15:04:05"))
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func (s *historyStore) EnsureLockTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INT PRIMARY KEY,
+			owner VARCHAR(255),
+			locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	return err
+}
+
+func (s *historyStore) TryTableLock(owner string) error {
+	_, err := s.db.Exec(fmt.Sprintf("INSERT INTO schema_migrations_lock (id, owner) VALUES (1, %s)", s.bind(1)), owner)
+	return err
+}
+
+func (s *historyStore) TableUnlock() error {
+	_, err := s.db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1")
+	return err
+}
+
+func (s *historyStore) TableLockHolder() (string, time.Time) {
+	var owner string
+	var lockedAt sql.NullTime
+	_ = s.db.QueryRow("SELECT COALESCE(owner, ''), locked_at FROM schema_migrations_lock WHERE id = 1").Scan(&owner, &lockedAt)
+	return owner, lockedAt.Time
+}
+
+// ForceUnlock 删除锁表中的锁记录，用于持有锁的进程异常退出后清理残留的锁。
+// 咨询锁随会话结束自动释放，不需要清理
+func ForceUnlock(db conn.DBAdapter) (string, time.Time, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return forceUnlock(store)
+}
+
+func forceUnlock(store MigrationStore) (string, time.Time, error) {
+	if err := store.EnsureLockTable(); err != nil {
+		return "", time.Time{}, err
+	}
+	holder, lockedAt := store.TableLockHolder()
+	return holder, lockedAt, store.TableUnlock()
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/lib/pq"
+)
+
+// lockTableStore 用内存模拟锁表
+type lockTableStore struct {
+	MigrationStore
+	owner string
+}
+
+func (s *lockTableStore) EnsureLockTable() error {
+	return nil
+}
+
+func (s *lockTableStore) TryTableLock(owner string) error {
+	if s.owner != "" {
+		return errors.New("duplicate key")
+	}
+	s.owner = owner
+	return nil
+}
+
+func (s *lockTableStore) TableUnlock() error {
+	s.owner = ""
+	return nil
+}
+
+func (s *lockTableStore) TableLockHolder() (string, time.Time) {
+	return s.owner, time.Now()
+}
+
+func TestTableLock(t *testing.T) {
+	store := &lockTableStore{}
+	lock, err := tableLock(store, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tableLock(store, time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expect lock timeout, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = tableLock(store, time.Millisecond)
+	if err != nil {
+		t.Fatalf("lock should be available after release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestForceUnlock(t *testing.T) {
+	store := &lockTableStore{owner: "host:42"}
+	holder, _, err := forceUnlock(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder != "host:42" || store.owner != "" {
+		t.Fatalf("unexpected holder %q, remaining owner %q", holder, store.owner)
+	}
+}
+
+// 咨询锁的其他错误不能退化为锁表，否则两个实例可能分别持有不同的锁
+func TestAdvisoryLockErrorNoFallback(t *testing.T) {
+	db, d := newFakeAdapter(consts.DBTypeMySQL)
+	d.fail = "GET_LOCK"
+	if _, err := AcquireLock(db, LockAdvisory, time.Second); err == nil {
+		t.Fatal("expect advisory lock error")
+	}
+	for _, s := range d.statements() {
+		if strings.Contains(s, "schema_migrations_lock") {
+			t.Fatalf("should not fall back to lock table: %s", s)
+		}
+	}
+}
+
+func TestAdvisoryLockUnsupported(t *testing.T) {
+	if !mysqlLockUnsupported(&mysql.MySQLError{Number: 1235}) {
+		t.Error("mysql 1235 should be unsupported")
+	}
+	if mysqlLockUnsupported(&mysql.MySQLError{Number: 2013}) {
+		t.Error("lost connection should not be unsupported")
+	}
+	if !postgresLockUnsupported(&pq.Error{Code: "42883"}) {
+		t.Error("undefined_function should be unsupported")
+	}
+	if postgresLockUnsupported(errors.New("connection refused")) {
+		t.Error("connection error should not be unsupported")
+	}
+}
@@ -1,29 +1,70 @@
 package migrate
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/jacktea/data-smith/pkg/conn"
-	"github.com/jacktea/data-smith/pkg/consts"
 	"github.com/jacktea/data-smith/pkg/logger"
-	"github.com/jacktea/data-smith/pkg/utils"
 )
 
 func CurrentVersion(db conn.DBAdapter) (string, error) {
-	row := db.GetConn().QueryRow("SELECT version FROM schema_migrations WHERE status = 'success' ORDER BY id DESC LIMIT 1")
-	var version string
-	err := row.Scan(&version)
-	if err == sql.ErrNoRows {
-		return "", nil
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return "", err
+	}
+	return store.CurrentVersion()
+}
+
+// AppliedRecords 按执行顺序返回迁移历史
+func AppliedRecords(db conn.DBAdapter) ([]*MigrationRecord, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return store.Records()
+}
+
+// AppliedVersions 已执行的版本集合，不包括可重复脚本
+func AppliedVersions(db conn.DBAdapter) (map[string]bool, error) {
+	records, err := AppliedRecords(db)
+	if err != nil {
+		return nil, err
+	}
+	return appliedVersions(records), nil
+}
+
+func appliedVersions(records []*MigrationRecord) map[string]bool {
+	applied := map[string]bool{}
+	for _, r := range records {
+		if r.Version != "" && r.Direction != DirectionDown && (r.Status == StatusSuccess || r.Status == StatusDeleted) {
+			applied[r.Version] = true
+		}
 	}
-	return version, err
+	return applied
 }
 
 func DryRunMigrations(db conn.DBAdapter, files []*MigrationFile) error {
 	logger.Info("开始模拟数据迁移")
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return err
+	}
+	if !store.TransactionalDDL() {
+		// DDL 会隐式提交事务，执行后无法回滚，只输出语句
+		logger.Warn("当前数据库的 DDL 会隐式提交事务，预览模式只输出待执行的语句，不执行")
+		for _, f := range files {
+			if err := printStatements(db, store, f); err != nil {
+				return err
+			}
+		}
+		logger.Info("模拟数据迁移成功")
+		return nil
+	}
 	conn := db.GetConn()
 	// 开始事务
 	tx, err := conn.Begin()
@@ -43,19 +84,33 @@ func DryRunMigrations(db conn.DBAdapter, files []*MigrationFile) error {
 
 	// 模拟执行每个文件
 	for _, f := range files {
-		content := f.GetContent()
-		msg := fmt.Sprintf("模拟执行脚本 %s__%s", f.Version, f.Title)
+		if f.NoTransaction() {
+			// 不能在事务中执行的脚本无法回滚，只输出语句
+			if err := printStatements(db, store, f); err != nil {
+				return err
+			}
+			continue
+		}
+		msg := fmt.Sprintf("模拟执行脚本 %s", f.Name())
 		logger.Info(msg)
-		// 执行 SQL
-		_, err = tx.Exec(utils.CleanTransaction(content))
+		stmts, err := scriptStatements(db, store, f)
 		if err != nil {
-			msg := fmt.Sprintf("模拟执行脚本 %s__%s 失败: %s", f.Version, f.Title, err.Error())
-			logger.Info(msg)
-			return errors.New(msg)
+			return err
+		}
+		// 执行 SQL，忽略脚本自带的事务控制语句
+		for _, stmt := range stmts {
+			if isTransactionControl(stmt) {
+				continue
+			}
+			if _, err = tx.Exec(stmt); err != nil {
+				msg := fmt.Sprintf("模拟执行脚本 %s 失败: %s", f.Name(), err.Error())
+				logger.Info(msg)
+				return errors.New(msg)
+			}
 		}
 
 		// 模拟记录版本
-		_, err = tx.Exec(`INSERT INTO schema_migrations (version, title) VALUES ($1, $2)`, f.Version, f.Title)
+		err = store.Record(tx, newRecord(f, 0, StatusSuccess))
 		if err != nil {
 			msg := fmt.Sprintf("记录版本失败: %s", err.Error())
 			logger.Info(msg)
@@ -66,13 +121,33 @@ func DryRunMigrations(db conn.DBAdapter, files []*MigrationFile) error {
 	return nil
 }
 
+// printStatements 输出脚本替换占位符、拆分后的语句，不执行
+func printStatements(db conn.DBAdapter, store MigrationStore, f *MigrationFile) error {
+	stmts, err := scriptStatements(db, store, f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("-- %s\n", f.Name())
+	for _, stmt := range stmts {
+		if isTransactionControl(stmt) {
+			continue
+		}
+		fmt.Println(strings.TrimSuffix(strings.TrimSpace(stmt), ";") + ";")
+	}
+	return nil
+}
+
 func ApplyMigrations(db conn.DBAdapter, files []*MigrationFile) error {
 	logger.Info("开始数据迁移")
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return err
+	}
 	for _, f := range files {
-		msg := fmt.Sprintf("操作脚本 %s__%s", f.Version, f.Title)
+		msg := fmt.Sprintf("操作脚本 %s", f.Name())
 		logger.Info(msg)
-		if err := applyMigration(db, f); err != nil {
-			msg := fmt.Sprintf("操作脚本 %s__%s 失败: %s", f.Version, f.Title, err.Error())
+		if err := applyMigration(db, store, f); err != nil {
+			msg := fmt.Sprintf("操作脚本 %s 失败: %s", f.Name(), err.Error())
 			logger.Info(msg)
 			return err
 		}
@@ -83,21 +158,19 @@ func ApplyMigrations(db conn.DBAdapter, files []*MigrationFile) error {
 
 func ResetDatabase(db conn.DBAdapter) error {
 	logger.Info("开始重置数据库")
-	conn := db.GetConn()
-	cfg := db.GetConfig()
-	query := ""
-	switch cfg.Type {
-	case consts.DBTypeMySQL:
-		query = fmt.Sprintf("DROP DATABASE IF EXISTS %s; CREATE DATABASE %s;", cfg.DBName, cfg.DBName)
-	case consts.DBTypePostgres:
-		query = fmt.Sprintf("DROP SCHEMA %s CASCADE; CREATE SCHEMA %s;", cfg.TableSchema, cfg.TableSchema)
-	default:
-		logger.Error("不支持的数据库类型")
-		return errors.New("不支持的数据库类型")
-	}
-	if query != "" {
-		_, err := conn.Exec(query)
-		if err != nil {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	// 在同一连接上执行，保证 USE 等会话级语句生效
+	c, err := db.GetConn().Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, query := range store.ResetStatements() {
+		if _, err := c.ExecContext(context.Background(), query); err != nil {
 			logger.Errorf("重置数据库失败: %s\n", err.Error())
 			return err
 		}
@@ -107,48 +180,136 @@ func ResetDatabase(db conn.DBAdapter) error {
 }
 
 func EnsureVersionTable(db conn.DBAdapter) error {
-	conn := db.GetConn()
-	cfg := db.GetConfig()
-	query := ""
-	switch cfg.Type {
-	case consts.DBTypeMySQL:
-		query = `CREATE TABLE IF NOT EXISTS schema_migrations (
-			id INT AUTO_INCREMENT PRIMARY KEY,
-			version VARCHAR(255) NOT NULL,
-			title VARCHAR(255),
-			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			execution_time INT,
-			status VARCHAR(50) DEFAULT 'success'
-		)`
-	case consts.DBTypePostgres:
-		query = `CREATE TABLE IF NOT EXISTS schema_migrations (
-			id SERIAL PRIMARY KEY,
-			version VARCHAR(255) NOT NULL,
-			title VARCHAR(255),
-			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			execution_time INTEGER,
-			status VARCHAR(50) DEFAULT 'success'
-		)`
-	default:
-		return errors.New("unsupported database type")
-	}
-	if query != "" {
-		_, err := conn.Exec(query)
+	store, err := NewMigrationStore(db)
+	if err != nil {
 		return err
 	}
-	return nil
+	return store.EnsureTable()
 }
 
-func applyMigration(db conn.DBAdapter, f *MigrationFile) error {
-	content := f.GetContent()
+// applyMigration 执行脚本并写入成功记录，可重复脚本每次从头执行
+func applyMigration(db conn.DBAdapter, store MigrationStore, f *MigrationFile) error {
+	return runScript(db, store, f, !f.Repeatable, func(e Execer, execTime int) error {
+		return store.Record(e, newRecord(f, execTime, StatusSuccess))
+	})
+}
+
+// runScript 执行脚本，成功后调用 done 写入结果。DDL 支持事务的数据库上整个脚本与 done 在一个事务中执行，
+// 失败时全部回滚；否则在同一连接上逐条执行，保证 SET 等会话级语句作用于后续语句，
+// 失败时记录失败语句的序号，resume 为 true 时再次执行从该语句继续
+func runScript(db conn.DBAdapter, store MigrationStore, f *MigrationFile, resume bool, done func(e Execer, execTime int) error) error {
+	if store.TransactionalDDL() && !f.NoTransaction() {
+		return runInTransaction(db, store, f, done)
+	}
+	stmts, err := scriptStatements(db, store, f)
+	if err != nil {
+		return err
+	}
+	from := 1
+	if resume {
+		n, err := store.FailedStatement(f.Version, f.historyDirection())
+		if err != nil {
+			return err
+		}
+		if n > 1 && n <= len(stmts) {
+			logger.Infof("上次执行在第 %d 条语句失败，从该语句继续执行", n)
+			from = n
+		}
+	}
+	ctx := context.Background()
+	c, err := db.GetConn().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
 	start := time.Now()
-	conn := db.GetConn()
-	_, err := conn.Exec(content)
+	failed, err := execStatements(ctx, c, stmts, from)
+	execTime := int(time.Since(start).Milliseconds())
+	if err != nil {
+		r := newRecord(f, execTime, StatusFailed)
+		r.FailedStatement = failed
+		_ = store.Record(db.GetConn(), r)
+		return err
+	}
+	return done(db.GetConn(), execTime)
+}
+
+// runInTransaction 在一个事务中执行脚本与 done，脚本自带的事务控制语句会被忽略
+func runInTransaction(db conn.DBAdapter, store MigrationStore, f *MigrationFile, done func(e Execer, execTime int) error) error {
+	all, err := scriptStatements(db, store, f)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := db.GetConn().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	for _, stmt := range all {
+		if !isTransactionControl(stmt) {
+			stmts = append(stmts, stmt)
+		}
+	}
+	start := time.Now()
+	_, err = execStatements(ctx, tx, stmts, 1)
 	execTime := int(time.Since(start).Milliseconds())
+	if err == nil {
+		err = done(tx, execTime)
+	}
 	if err != nil {
-		_, _ = conn.Exec(`INSERT INTO schema_migrations (version, title, execution_time, status) VALUES ($1, $2, $3, $4)`, f.Version, f.Title, execTime, "failed")
+		_ = tx.Rollback()
+		_ = store.Record(db.GetConn(), newRecord(f, execTime, StatusFailed))
 		return err
 	}
-	_, err = conn.Exec(`INSERT INTO schema_migrations (version, title, execution_time) VALUES ($1, $2, $3)`, f.Version, f.Title, execTime)
-	return err
+	return tx.Commit()
+}
+
+// scriptStatements 脚本拆分后的语句，JSON 数据迁移脚本按目标库方言生成语句
+func scriptStatements(db conn.DBAdapter, store MigrationStore, f *MigrationFile) ([]string, error) {
+	content, err := f.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	if f.Ext == "json" {
+		return RenderDataMigration(db, content)
+	}
+	return store.SplitStatements(content), nil
+}
+
+func newRecord(f *MigrationFile, execTime int, status string) *MigrationRecord {
+	return &MigrationRecord{
+		Version:       f.Version,
+		Title:         f.Title,
+		Script:        f.ScriptName(),
+		Checksum:      f.Checksum(),
+		ExecutionTime: execTime,
+		Status:        status,
+		Direction:     f.historyDirection(),
+	}
+}
+
+var transactionControl = regexp.MustCompile(`(?i)^(begin|start\s+transaction|commit|end)(\s+(work|transaction))?$`)
+
+func isTransactionControl(stmt string) bool {
+	return transactionControl.MatchString(strings.TrimSpace(stmt))
+}
+
+// execStatements 从第 from 条语句(从 1 开始)开始依次执行，失败时返回失败语句的序号
+func execStatements(ctx context.Context, e Execer, stmts []string, from int) (int, error) {
+	for i := from - 1; i < len(stmts); i++ {
+		if _, err := e.ExecContext(ctx, stmts[i]); err != nil {
+			return i + 1, fmt.Errorf("第 %d 条语句执行失败: %w", i+1, err)
+		}
+	}
+	return 0, nil
+}
+
+// ClearFailedMigrations 删除执行失败的迁移记录
+func ClearFailedMigrations(db conn.DBAdapter) (int64, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return 0, err
+	}
+	return store.ClearFailed()
 }
@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+type fakeExecer struct {
+	executed []string
+	fail     string
+}
+
+func (e *fakeExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if query == e.fail {
+		return nil, errors.New("boom")
+	}
+	e.executed = append(e.executed, query)
+	return nil, nil
+}
+
+func TestExecStatementsResume(t *testing.T) {
+	stmts := []string{"a", "b", "c", "d"}
+	e := &fakeExecer{fail: "c"}
+	failed, err := execStatements(context.Background(), e, stmts, 1)
+	if err == nil || failed != 3 {
+		t.Fatalf("expect failure at statement 3, got %d %v", failed, err)
+	}
+	e = &fakeExecer{}
+	if _, err := execStatements(context.Background(), e, stmts, failed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.executed, []string{"c", "d"}) {
+		t.Errorf("resume should start from the failed statement, got %v", e.executed)
+	}
+}
+
+func TestNoTransaction(t *testing.T) {
+	cases := map[string]bool{
+		"-- datasmith:no-transaction\nCREATE INDEX CONCURRENTLY i ON t (a);":                true,
+		"\n-- 创建索引\n--   datasmith:no-transaction  \nCREATE INDEX CONCURRENTLY i ON t (a);": true,
+		"CREATE TABLE t (a INT);\n-- datasmith:no-transaction":                              false,
+		"CREATE TABLE t (a INT);": false,
+	}
+	for content, expect := range cases {
+		if got := (&MigrationFile{Content: content}).NoTransaction(); got != expect {
+			t.Errorf("%q: got %v, expect %v", content, got, expect)
+		}
+	}
+}
+
+func TestIsTransactionControl(t *testing.T) {
+	for _, stmt := range []string{"BEGIN", "begin work", "START TRANSACTION", "COMMIT", " end "} {
+		if !isTransactionControl(stmt) {
+			t.Errorf("%q should be transaction control", stmt)
+		}
+	}
+	for _, stmt := range []string{"BEGIN_DATE = 1", "COMMIT PREPARED 'x'", "CREATE FUNCTION f() AS $$ BEGIN END $$"} {
+		if isTransactionControl(stmt) {
+			t.Errorf("%q should not be transaction control", stmt)
+		}
+	}
+}
+
+func TestDryRunWithoutTransactionalDDL(t *testing.T) {
+	db, d := newFakeAdapter(consts.DBTypeMySQL)
+	files := []*MigrationFile{
+		{Version: "V1", Title: "init", Content: "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);"},
+	}
+	if err := DryRunMigrations(db, files); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.statements(); len(got) != 0 {
+		t.Errorf("dry run must not touch the schema or the history table, got %v", got)
+	}
+}
+
+func TestDryRunWithTransactionalDDL(t *testing.T) {
+	db, d := newFakeAdapter(consts.DBTypePostgres)
+	files := []*MigrationFile{
+		{Version: "V1", Title: "init", Content: "CREATE TABLE a (id INT);"},
+		{Version: "V2", Title: "index", Content: "-- datasmith:no-transaction\nCREATE INDEX CONCURRENTLY i ON a (id);"},
+	}
+	if err := DryRunMigrations(db, files); err != nil {
+		t.Fatal(err)
+	}
+	got := d.statements()
+	if len(got) == 0 || got[0] != "BEGIN " || got[len(got)-1] != "ROLLBACK " {
+		t.Fatalf("dry run should run inside a rolled back transaction, got %v", got)
+	}
+	for _, s := range got {
+		if strings.Contains(s, "CONCURRENTLY") || s == "COMMIT " {
+			t.Errorf("unexpected statement %q", s)
+		}
+	}
+}
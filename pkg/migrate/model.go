@@ -1,6 +1,13 @@
 package migrate
 
-import "os"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 type MigrationFile struct {
 	Version   string
@@ -8,16 +15,126 @@ type MigrationFile struct {
 	Direction string // up/down
 	Ext       string // sql/json
 	Path      string
-	Content   string
+	// Repeatable R__name.sql 形式的可重复脚本，没有版本号，内容变化后重新执行
+	Repeatable bool
+	// Script 相对于脚本目录的路径，记录到迁移历史中
+	Script  string
+	Content string
+	// Placeholders 替换脚本中 ${name} 占位符的取值
+	Placeholders *Placeholders
 }
 
-func (m *MigrationFile) GetContent() string {
+// historyDirection 写入迁移历史的方向，未标注方向的脚本为 up
+func (m *MigrationFile) historyDirection() string {
+	if m.Direction == DirectionDown {
+		return DirectionDown
+	}
+	return DirectionUp
+}
+
+// RawContent 未替换占位符的脚本内容
+func (m *MigrationFile) RawContent() (string, error) {
 	if m.Content != "" {
-		return m.Content
+		return m.Content, nil
 	}
 	content, err := os.ReadFile(m.Path)
 	if err != nil {
-		return ""
+		return "", err
+	}
+	return string(content), nil
+}
+
+// GetContent 替换占位符后的脚本内容，存在未定义的占位符时返回错误
+func (m *MigrationFile) GetContent() (string, error) {
+	content, err := m.RawContent()
+	if err != nil {
+		return "", err
+	}
+	if m.hasDirective(NoPlaceholdersDirective) {
+		return content, nil
+	}
+	content, err = m.Placeholders.Replace(content)
+	if err != nil {
+		return "", fmt.Errorf("脚本 %s: %w", m.ScriptName(), err)
+	}
+	return content, nil
+}
+
+// Check 执行前检查脚本：能否读取、占位符是否都已定义、JSON 数据迁移脚本能否解析
+func (m *MigrationFile) Check() error {
+	content, err := m.GetContent()
+	if err != nil {
+		return err
+	}
+	if m.Ext == "json" {
+		if _, err := ParseDataMigration(content); err != nil {
+			return fmt.Errorf("脚本 %s: %w", m.ScriptName(), err)
+		}
+	}
+	return nil
+}
+
+const (
+	// NoTransactionDirective 写在脚本开头注释中，脚本不在事务中执行，
+	// 用于 CREATE INDEX CONCURRENTLY 等不能在事务中执行的语句
+	NoTransactionDirective = "datasmith:no-transaction"
+	// NoPlaceholdersDirective 写在脚本开头注释中，脚本中的 ${name} 原样执行，不替换占位符
+	NoPlaceholdersDirective = "datasmith:no-placeholders"
+)
+
+// NoTransaction 脚本开头的注释中是否包含 NoTransactionDirective
+func (m *MigrationFile) NoTransaction() bool {
+	return m.hasDirective(NoTransactionDirective)
+}
+
+// hasDirective 脚本开头连续的注释行中是否有内容为 directive 的一行
+func (m *MigrationFile) hasDirective(directive string) bool {
+	content, _ := m.RawContent()
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if strings.TrimSpace(strings.TrimPrefix(line, "--")) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// Name 脚本的显示名称
+func (m *MigrationFile) Name() string {
+	if m.Repeatable {
+		return "R__" + m.Title
+	}
+	return m.Version + "__" + m.Title
+}
+
+// ScriptName 记录到迁移历史中的脚本路径
+func (m *MigrationFile) ScriptName() string {
+	if m.Script != "" {
+		return m.Script
+	}
+	return filepath.Base(m.Path)
+}
+
+// Checksum 脚本原始内容(未替换占位符)的 SHA-256 校验和，计算前统一换行符并去掉 BOM 与行尾空白，
+// 避免仅因编辑器、操作系统或环境差异导致校验失败
+func (m *MigrationFile) Checksum() string {
+	content, _ := m.RawContent()
+	sum := sha256.Sum256([]byte(normalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeContent(content string) string {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
 	}
-	return string(content)
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
 }
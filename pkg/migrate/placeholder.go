@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+// PlaceholderEnvPrefix 环境变量中占位符的前缀，如 DATASMITH_PLACEHOLDER_OWNER 对应 ${owner}
+const PlaceholderEnvPrefix = "DATASMITH_PLACEHOLDER_"
+
+// placeholderRe 匹配 ${name}，以及转义写法 $${name}(替换为原样的 ${name})
+var placeholderRe = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// Placeholders 脚本中 ${name} 占位符的取值，优先级从高到低为：
+// 命令行参数、环境变量、配置文件、内置占位符(schema、user、database)
+type Placeholders struct {
+	overrides  map[string]string
+	configured map[string]string
+	builtins   map[string]string
+	lookupEnv  func(string) (string, bool)
+}
+
+// NewPlaceholders 由目标库连接配置、配置文件中的占位符与命令行参数创建占位符
+func NewPlaceholders(cfg *config.ConnConfig, configured, overrides map[string]string) *Placeholders {
+	schema := cfg.TableSchema
+	if schema == "" && cfg.Type == consts.DBTypeMySQL {
+		schema = cfg.DBName
+	}
+	return &Placeholders{
+		overrides:  overrides,
+		configured: configured,
+		builtins: map[string]string{
+			"schema":   schema,
+			"user":     cfg.User,
+			"database": cfg.DBName,
+		},
+		lookupEnv: os.LookupEnv,
+	}
+}
+
+// Lookup 查找占位符的取值
+func (p *Placeholders) Lookup(name string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	if v, ok := p.overrides[name]; ok {
+		return v, true
+	}
+	if p.lookupEnv != nil {
+		if v, ok := p.lookupEnv(PlaceholderEnvPrefix + envName(name)); ok {
+			return v, true
+		}
+	}
+	if v, ok := p.configured[name]; ok {
+		return v, true
+	}
+	v, ok := p.builtins[name]
+	return v, ok
+}
+
+// Replace 替换 content 中的占位符，$${name} 输出为 ${name} 不做替换，存在未定义的占位符时返回错误
+func (p *Placeholders) Replace(content string) (string, error) {
+	undefined := map[string]bool{}
+	content = placeholderRe.ReplaceAllStringFunc(content, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		name := m[2 : len(m)-1]
+		v, ok := p.Lookup(name)
+		if !ok {
+			undefined[name] = true
+			return m
+		}
+		return v
+	})
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("未定义的占位符: %s", strings.Join(names, ", "))
+	}
+	return content, nil
+}
+
+// envName 占位符名称对应的环境变量后缀：转为大写，'.' 与 '-' 替换为 '_'
+func envName(name string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(name))
+}
+
+// ParsePlaceholders 解析 key=value 形式的占位符参数
+func ParsePlaceholders(pairs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("无效的占位符参数 %q，应为 key=value", pair)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
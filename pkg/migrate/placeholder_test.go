@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+func TestPlaceholders(t *testing.T) {
+	p := NewPlaceholders(
+		&config.ConnConfig{Type: consts.DBTypeMySQL, User: "app", DBName: "shop"},
+		map[string]string{"owner": "config_owner", "tablespace": "ts_config"},
+		map[string]string{"owner": "cli_owner"},
+	)
+	env := map[string]string{"DATASMITH_PLACEHOLDER_TABLESPACE": "ts_env", "DATASMITH_PLACEHOLDER_APP_ENV": "prod"}
+	p.lookupEnv = func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	got, err := p.Replace("CREATE TABLE ${schema}.t (id INT) TABLESPACE ${tablespace}; -- ${owner} ${user} ${database} ${app.env} $1 $$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "CREATE TABLE shop.t (id INT) TABLESPACE ts_env; -- cli_owner app shop prod $1 $$"
+	if got != expect {
+		t.Errorf("got %q, expect %q", got, expect)
+	}
+
+	if _, err := p.Replace("GRANT ALL ON t TO ${reader}, ${writer};"); err == nil || !strings.Contains(err.Error(), "reader, writer") {
+		t.Errorf("expect undefined placeholder error, got %v", err)
+	}
+}
+
+func TestChecksumUsesRawContent(t *testing.T) {
+	raw := "ALTER TABLE t OWNER TO ${owner};"
+	a := &MigrationFile{Content: raw, Placeholders: NewPlaceholders(&config.ConnConfig{}, map[string]string{"owner": "a"}, nil)}
+	b := &MigrationFile{Content: raw, Placeholders: NewPlaceholders(&config.ConnConfig{}, map[string]string{"owner": "b"}, nil)}
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("checksum should not depend on placeholder values")
+	}
+	content, err := a.GetContent()
+	if err != nil || content != "ALTER TABLE t OWNER TO a;" {
+		t.Errorf("unexpected content %q, %v", content, err)
+	}
+	if _, err := (&MigrationFile{Content: raw}).GetContent(); err == nil {
+		t.Errorf("expect error for undefined placeholder")
+	}
+}
+
+func TestPlaceholderEscape(t *testing.T) {
+	p := NewPlaceholders(&config.ConnConfig{}, map[string]string{"owner": "app"}, nil)
+	got, err := p.Replace("SELECT '$${owner}', '${owner}', '$${undefined}';")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "SELECT '${owner}', 'app', '${undefined}';"; got != expect {
+		t.Errorf("got %q, expect %q", got, expect)
+	}
+
+	f := &MigrationFile{Content: "-- datasmith:no-placeholders\nSELECT '${template}', '$${x}';"}
+	content, err := f.GetContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != f.Content {
+		t.Errorf("no-placeholders script should be unchanged, got %q", content)
+	}
+}
@@ -0,0 +1,41 @@
+package migrate
+
+import "github.com/jacktea/data-smith/pkg/conn"
+
+// PendingRepeatables 返回从未执行或内容与最后一次成功执行时不同的可重复脚本
+func PendingRepeatables(db conn.DBAdapter, files []*MigrationFile) ([]*MigrationFile, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.Records()
+	if err != nil {
+		return nil, err
+	}
+	return pendingRepeatables(files, records), nil
+}
+
+func pendingRepeatables(files []*MigrationFile, records []*MigrationRecord) []*MigrationFile {
+	applied := repeatableChecksums(records)
+	var pending []*MigrationFile
+	for _, f := range files {
+		if !f.Repeatable {
+			continue
+		}
+		if sum, ok := applied[f.Title]; !ok || sum != f.Checksum() {
+			pending = append(pending, f)
+		}
+	}
+	return pending
+}
+
+// repeatableChecksums 每个可重复脚本最后一次成功执行时的校验和，可重复脚本的记录版本号为空
+func repeatableChecksums(records []*MigrationRecord) map[string]string {
+	applied := map[string]string{}
+	for _, r := range records {
+		if r.Version == "" && r.Status == StatusSuccess {
+			applied[r.Title] = r.Checksum
+		}
+	}
+	return applied
+}
@@ -0,0 +1,30 @@
+package migrate
+
+import "testing"
+
+func TestPendingRepeatables(t *testing.T) {
+	views := &MigrationFile{Title: "views", Repeatable: true, Content: "CREATE OR REPLACE VIEW v AS SELECT 2;"}
+	funcs := &MigrationFile{Title: "funcs", Repeatable: true, Content: "CREATE OR REPLACE FUNCTION f() ..."}
+	seed := &MigrationFile{Title: "seed", Repeatable: true, Content: "INSERT INTO dict VALUES (1);"}
+	versioned := &MigrationFile{Version: "V1", Title: "views", Content: "CREATE TABLE t (id INT);"}
+	old := &MigrationFile{Content: "CREATE OR REPLACE VIEW v AS SELECT 1;"}
+
+	records := []*MigrationRecord{
+		{Version: "V1", Title: "views", Checksum: versioned.Checksum(), Status: StatusSuccess},
+		{Title: "views", Checksum: old.Checksum(), Status: StatusSuccess},
+		{Title: "funcs", Checksum: funcs.Checksum(), Status: StatusSuccess},
+		{Title: "seed", Checksum: seed.Checksum(), Status: StatusFailed},
+	}
+	pending := pendingRepeatables([]*MigrationFile{versioned, views, funcs, seed}, records)
+	if len(pending) != 2 || pending[0] != views || pending[1] != seed {
+		t.Errorf("expect changed and failed repeatable scripts, got %v", pending)
+	}
+
+	statuses := statuses([]*MigrationFile{versioned, funcs, views}, records, "V1")
+	if len(statuses) != 3 || statuses[0].Version != "V1" {
+		t.Fatalf("repeatable scripts should follow versioned ones, got %+v", statuses)
+	}
+	if statuses[1].State != StateApplied || statuses[2].State != StatePending {
+		t.Errorf("unexpected repeatable states %q %q", statuses[1].State, statuses[2].State)
+	}
+}
@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/logger"
+	"github.com/jacktea/data-smith/pkg/utils"
+)
+
+// RollbackStep 回滚一个版本：执行 File 并将 Records 标记为已回滚
+type RollbackStep struct {
+	Version string
+	Title   string
+	File    *MigrationFile
+	Records []*MigrationRecord
+}
+
+// PlanRollback 按版本从高到低计算需要回滚的版本。
+// to 不为空时回滚所有高于 to 的版本，否则回滚最近的 steps 个版本；
+// 任一版本缺少 down 脚本时返回错误，不执行任何回滚
+func PlanRollback(records []*MigrationRecord, files []*MigrationFile, to string, steps int) ([]*RollbackStep, error) {
+	if to == "" && steps <= 0 {
+		return nil, errors.New("需要指定回滚的目标版本或步数")
+	}
+	applied := map[string]*RollbackStep{}
+	for _, r := range records {
+		if r.Version == "" || r.Direction == DirectionDown || (r.Status != StatusSuccess && r.Status != StatusDeleted) {
+			continue
+		}
+		step, ok := applied[r.Version]
+		if !ok {
+			step = &RollbackStep{Version: r.Version}
+			applied[r.Version] = step
+		}
+		step.Title = r.Title
+		step.Records = append(step.Records, r)
+	}
+	versions := make([]string, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return utils.CompareVersion(versions[i], versions[j]) > 0
+	})
+
+	downs := map[string]*MigrationFile{}
+	for _, f := range files {
+		if f.Direction == "down" {
+			downs[f.Version] = f
+		}
+	}
+	var plan []*RollbackStep
+	for _, v := range versions {
+		if to != "" && utils.CompareVersion(v, to) <= 0 {
+			break
+		}
+		if to == "" && len(plan) >= steps {
+			break
+		}
+		step := applied[v]
+		step.File = downs[v]
+		if step.File == nil {
+			return nil, fmt.Errorf("版本 %s__%s 没有 down 脚本，无法回滚", step.Version, step.Title)
+		}
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
+
+// RollbackMigrations 按顺序执行 down 脚本，成功后将对应的历史记录标记为已回滚
+func RollbackMigrations(db conn.DBAdapter, plan []*RollbackStep) error {
+	logger.Info("开始回滚迁移")
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return err
+	}
+	for _, step := range plan {
+		logger.Infof("回滚脚本 %s__%s", step.Version, step.Title)
+		if err := rollbackStep(db, store, step); err != nil {
+			return fmt.Errorf("回滚脚本 %s__%s 失败: %w", step.Version, step.Title, err)
+		}
+	}
+	logger.Info("回滚迁移成功")
+	return nil
+}
+
+// rollbackStep 与 up 脚本相同的方式执行 down 脚本：PostgreSQL 上除非声明 no-transaction 否则在一个事务中执行，
+// 并在同一事务中标记回滚；MySQL 上逐条执行，失败时记录失败语句的序号，再次回滚时从该语句继续
+func rollbackStep(db conn.DBAdapter, store MigrationStore, step *RollbackStep) error {
+	ids := make([]int64, 0, len(step.Records))
+	for _, r := range step.Records {
+		ids = append(ids, r.ID)
+	}
+	// 之前回滚失败留下的记录一并标记，避免版本状态停留在失败
+	records, err := store.Records()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.Version == step.Version && r.Status == StatusFailed && r.Direction == DirectionDown {
+			ids = append(ids, r.ID)
+		}
+	}
+	return runScript(db, store, step.File, true, func(e Execer, execTime int) error {
+		logger.Infof("回滚耗时 %d ms", execTime)
+		for _, id := range ids {
+			if err := store.SetStatus(e, id, StatusRolledBack); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
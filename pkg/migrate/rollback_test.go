@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+func TestPlanRollback(t *testing.T) {
+	records := []*MigrationRecord{
+		{ID: 1, Version: "V1", Status: StatusSuccess},
+		{ID: 2, Version: "V2", Status: StatusSuccess},
+		{ID: 3, Version: "V2.1", Status: StatusRolledBack},
+		{ID: 4, Version: "V3", Status: StatusFailed},
+		{ID: 5, Version: "V3", Status: StatusSuccess},
+		{ID: 6, Version: "V10", Status: StatusSuccess},
+	}
+	files := []*MigrationFile{
+		{Version: "V1", Direction: "down"},
+		{Version: "V2", Direction: "down"},
+		{Version: "V2.1", Direction: "down"},
+		{Version: "V3", Direction: "up"},
+		{Version: "V3", Direction: "down"},
+		{Version: "V10", Direction: "down"},
+	}
+	versions := func(plan []*RollbackStep) []string {
+		var vs []string
+		for _, s := range plan {
+			vs = append(vs, s.Version)
+		}
+		return vs
+	}
+
+	plan, err := PlanRollback(records, files, "V1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(plan); !reflect.DeepEqual(got, []string{"V10", "V3", "V2"}) {
+		t.Errorf("rollback to V1: got %v", got)
+	}
+	if len(plan[1].Records) != 1 || plan[1].Records[0].ID != 5 {
+		t.Errorf("only successful rows should be marked, got %+v", plan[1].Records)
+	}
+
+	plan, err = PlanRollback(records, files, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(plan); !reflect.DeepEqual(got, []string{"V10", "V3"}) {
+		t.Errorf("rollback 2 steps: got %v", got)
+	}
+
+	if _, err := PlanRollback(records, files[1:], "", 10); err == nil {
+		t.Errorf("expect error when down script is missing")
+	}
+}
+
+func TestRollbackInTransaction(t *testing.T) {
+	db, d := newFakeAdapter(consts.DBTypePostgres)
+	plan := []*RollbackStep{{
+		Version: "V2", Title: "add",
+		File:    &MigrationFile{Version: "V2", Title: "add", Direction: "down", Content: "DROP TABLE b;"},
+		Records: []*MigrationRecord{{ID: 7, Version: "V2", Status: StatusSuccess}},
+	}}
+	if err := RollbackMigrations(db, plan); err != nil {
+		t.Fatal(err)
+	}
+	got := d.statements()
+	begin, drop, mark, commit := -1, -1, -1, -1
+	for i, s := range got {
+		switch {
+		case s == "BEGIN ":
+			begin = i
+		case strings.Contains(s, "DROP TABLE b"):
+			drop = i
+		case strings.HasPrefix(s, "EXEC UPDATE schema_migrations SET status"):
+			mark = i
+		case s == "COMMIT ":
+			commit = i
+		}
+	}
+	if !(begin >= 0 && begin < drop && drop < mark && mark < commit) {
+		t.Errorf("down script and history update should run in one transaction, got %v", got)
+	}
+}
+
+func TestRollbackRecordsFailedStatement(t *testing.T) {
+	db, d := newFakeAdapter(consts.DBTypeMySQL)
+	d.fail = "DROP TABLE c"
+	plan := []*RollbackStep{{
+		Version: "V2", Title: "add",
+		File:    &MigrationFile{Version: "V2", Title: "add", Direction: "down", Content: "DROP TABLE b;\nDROP TABLE c;"},
+		Records: []*MigrationRecord{{ID: 7, Version: "V2", Status: StatusSuccess}},
+	}}
+	if err := RollbackMigrations(db, plan); err == nil {
+		t.Fatal("expect rollback error")
+	}
+	var recorded, byDirection bool
+	for _, s := range d.statements() {
+		// 断点按方向查询，不会取到 up 脚本的失败记录
+		if strings.HasPrefix(s, "QUERY SELECT status, COALESCE(failed_statement, 0)") && strings.Contains(s, "direction") {
+			byDirection = true
+		}
+		if strings.HasPrefix(s, "EXEC UPDATE schema_migrations SET status") {
+			t.Errorf("failed rollback should not mark records rolled back")
+		}
+		if strings.HasPrefix(s, "EXEC INSERT INTO schema_migrations") && strings.Contains(s, "direction") {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Error("failed rollback should be recorded with its direction")
+	}
+	if !byDirection {
+		t.Error("failed statement should be looked up by direction")
+	}
+}
@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+var (
+	delimiterRe = regexp.MustCompile(`(?i)^\s*DELIMITER\s+(\S+)[^\n]*(\n|$)`)
+	dollarTagRe = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// SplitStatements 将脚本拆分为可逐条执行的语句
+// 字符串、引用标识符与注释中的分号不作为分隔符，只包含注释的片段会被丢弃；
+// MySQL 支持反斜杠转义、# 注释与 DELIMITER 指令，PostgreSQL 支持 $tag$ 字符串与 E'...' 转义字符串
+func SplitStatements(content string, dbType consts.DBType) []string {
+	mysql := dbType == consts.DBTypeMySQL
+	var stmts []string
+	var b strings.Builder
+	hasCode := false
+	delimiter := ";"
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); hasCode && s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+		hasCode = false
+	}
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		// DELIMITER 指令只能出现在语句开头
+		if mysql && !hasCode {
+			if m := delimiterRe.FindStringSubmatch(rest); m != nil {
+				delimiter = m[1]
+				b.Reset()
+				i += len(m[0])
+				continue
+			}
+		}
+		if strings.HasPrefix(rest, delimiter) {
+			flush()
+			i += len(delimiter)
+			continue
+		}
+		c := content[i]
+		switch {
+		case strings.HasPrefix(rest, "--") && (!mysql || len(rest) == 2 || isSpace(rest[2])), mysql && c == '#':
+			// 行注释
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			b.WriteString(rest[:n])
+			i += n
+		case strings.HasPrefix(rest, "/*"):
+			// MySQL 的 /*! ... */ 为可执行注释
+			if mysql && strings.HasPrefix(rest, "/*!") {
+				hasCode = true
+			}
+			n := strings.Index(rest[2:], "*/")
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 4
+			}
+			b.WriteString(rest[:n])
+			i += n
+		case c == '\'' || c == '"' || (mysql && c == '`'):
+			escape := mysql && c != '`'
+			if !mysql && c == '\'' && i > 0 && (content[i-1] == 'E' || content[i-1] == 'e') && (i == 1 || !isIdentChar(content[i-2])) {
+				escape = true
+			}
+			n := quotedLen(rest, c, escape)
+			b.WriteString(rest[:n])
+			hasCode = true
+			i += n
+		case !mysql && c == '$' && (i == 0 || !isIdentChar(content[i-1])):
+			tag := dollarTagRe.FindString(rest)
+			if tag == "" {
+				b.WriteByte(c)
+				hasCode = true
+				i++
+				continue
+			}
+			n := strings.Index(rest[len(tag):], tag)
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 2 * len(tag)
+			}
+			b.WriteString(rest[:n])
+			hasCode = true
+			i += n
+		default:
+			b.WriteByte(c)
+			if !isSpace(c) {
+				hasCode = true
+			}
+			i++
+		}
+	}
+	flush()
+	return stmts
+}
+
+// quotedLen 返回以 quote 开头的引用串(含结束引号)的长度，未结束时返回剩余长度
+func quotedLen(s string, quote byte, escape bool) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if escape {
+				i++
+			}
+		case quote:
+			// 连续两个引号表示转义
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbType  consts.DBType
+		content string
+		expect  []string
+	}{
+		{
+			"mysql basic", consts.DBTypeMySQL,
+			"-- create\nCREATE TABLE a (id INT);\n# comment; here\nINSERT INTO a VALUES (1), (2);\n/* trailing; */\n",
+			[]string{"-- create\nCREATE TABLE a (id INT)", "# comment; here\nINSERT INTO a VALUES (1), (2)"},
+		},
+		{
+			"mysql quotes", consts.DBTypeMySQL,
+			"INSERT INTO t VALUES ('a;b', 'it''s', 'x\\';y', \"q;\");UPDATE `t;x` SET v = 1",
+			[]string{"INSERT INTO t VALUES ('a;b', 'it''s', 'x\\';y', \"q;\")", "UPDATE `t;x` SET v = 1"},
+		},
+		{
+			"mysql delimiter", consts.DBTypeMySQL,
+			"DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END$$\nDELIMITER ;\nCALL p();",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "CALL p()"},
+		},
+		{
+			"mysql executable comment", consts.DBTypeMySQL,
+			"/*!40101 SET NAMES utf8 */;\nSELECT 1;",
+			[]string{"/*!40101 SET NAMES utf8 */", "SELECT 1"},
+		},
+		{
+			"postgres dollar quote", consts.DBTypePostgres,
+			"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\nDO $body$ BEGIN PERFORM 1; END $body$;",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "DO $body$ BEGIN PERFORM 1; END $body$"},
+		},
+		{
+			"postgres strings", consts.DBTypePostgres,
+			"SELECT 'a\\'; SELECT E'b\\';c'; SELECT $1::text;",
+			[]string{"SELECT 'a\\'", "SELECT E'b\\';c'", "SELECT $1::text"},
+		},
+	}
+	for _, tt := range tests {
+		got := SplitStatements(tt.content, tt.dbType)
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("%s: got %q, expect %q", tt.name, got, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/utils"
+)
+
+// MigrationState 脚本的执行状态
+type MigrationState string
+
+const (
+	StateApplied MigrationState = "applied"
+	StatePending MigrationState = "pending"
+	StateFailed  MigrationState = "failed"
+	// StateIgnored 版本低于当前版本且未执行，只在指定 --out-of-order 时执行
+	StateIgnored MigrationState = "ignored"
+	// StateOutOfOrder 在更高版本之后才执行
+	StateOutOfOrder MigrationState = "out of order"
+	// StateMissing 已执行但本地找不到对应脚本
+	StateMissing MigrationState = "missing"
+)
+
+// MigrationStatus 一个版本的执行状态
+type MigrationStatus struct {
+	Version       string         `json:"version"`
+	Title         string         `json:"title"`
+	Repeatable    bool           `json:"repeatable,omitempty"`
+	Script        string         `json:"script,omitempty"`
+	State         MigrationState `json:"state"`
+	AppliedAt     *time.Time     `json:"applied_at,omitempty"`
+	ExecutionTime int            `json:"execution_time"`
+}
+
+// MigrationStatuses 读取迁移历史并返回每个 up 脚本的状态
+func MigrationStatuses(db conn.DBAdapter, files []*MigrationFile) ([]*MigrationStatus, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.Records()
+	if err != nil {
+		return nil, err
+	}
+	current, err := store.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	return statuses(files, records, current), nil
+}
+
+func statuses(files []*MigrationFile, records []*MigrationRecord, current string) []*MigrationStatus {
+	// 每个版本取 up 脚本的最后一条记录，并标记在更高版本之后才成功执行的版本；
+	// 回滚失败写入的 down 记录不影响 up 脚本的状态
+	latest := map[string]*MigrationRecord{}
+	outOfOrder := map[string]bool{}
+	highest := ""
+	for _, r := range records {
+		if r.Version == "" || r.Direction == DirectionDown {
+			continue
+		}
+		latest[r.Version] = r
+		if r.Status != StatusSuccess && r.Status != StatusDeleted {
+			continue
+		}
+		if highest != "" && utils.CompareVersion(r.Version, highest) < 0 {
+			outOfOrder[r.Version] = true
+		} else {
+			highest = r.Version
+			delete(outOfOrder, r.Version)
+		}
+	}
+
+	var result, repeatables []*MigrationStatus
+	local := map[string]bool{}
+	applied := repeatableChecksums(records)
+	for _, f := range files {
+		if f.Repeatable {
+			repeatables = append(repeatables, repeatableStatus(f, records, applied))
+			continue
+		}
+		if f.Direction == "down" || local[f.Version] {
+			continue
+		}
+		local[f.Version] = true
+		s := &MigrationStatus{Version: f.Version, Title: f.Title, Script: f.ScriptName()}
+		r := latest[f.Version]
+		switch {
+		case r != nil && (r.Status == StatusSuccess || r.Status == StatusDeleted):
+			s.State = StateApplied
+			if outOfOrder[f.Version] {
+				s.State = StateOutOfOrder
+			}
+		case r != nil && r.Status == StatusFailed:
+			s.State = StateFailed
+		case current != "" && utils.CompareVersion(f.Version, current) < 0:
+			s.State = StateIgnored
+		default:
+			s.State = StatePending
+		}
+		if r != nil && s.State != StatePending && s.State != StateIgnored {
+			s.fill(r)
+		}
+		result = append(result, s)
+	}
+	for v, r := range latest {
+		if local[v] || (r.Status != StatusSuccess && r.Status != StatusDeleted) {
+			continue
+		}
+		s := &MigrationStatus{Version: r.Version, Title: r.Title, Script: r.Script, State: StateMissing}
+		s.fill(r)
+		result = append(result, s)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return utils.CompareVersion(result[i].Version, result[j].Version) < 0
+	})
+	// 可重复脚本在所有版本之后执行
+	return append(result, repeatables...)
+}
+
+// repeatableStatus 可重复脚本的状态：内容与最后一次成功执行时相同为 applied，否则为 pending
+func repeatableStatus(f *MigrationFile, records []*MigrationRecord, applied map[string]string) *MigrationStatus {
+	s := &MigrationStatus{Title: f.Title, Repeatable: true, Script: f.ScriptName(), State: StatePending}
+	var last *MigrationRecord
+	for _, r := range records {
+		if r.Version == "" && r.Title == f.Title {
+			last = r
+		}
+	}
+	switch {
+	case last == nil:
+	case last.Status == StatusFailed:
+		s.State = StateFailed
+		s.fill(last)
+	case applied[f.Title] == f.Checksum():
+		s.State = StateApplied
+		s.fill(last)
+	}
+	return s
+}
+
+func (s *MigrationStatus) fill(r *MigrationRecord) {
+	if !r.AppliedAt.IsZero() {
+		t := r.AppliedAt
+		s.AppliedAt = &t
+	}
+	s.ExecutionTime = r.ExecutionTime
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatuses(t *testing.T) {
+	files := []*MigrationFile{
+		{Version: "V1", Title: "init"},
+		{Version: "V2", Title: "skipped"},
+		{Version: "V3", Title: "late"},
+		{Version: "V4", Title: "latest"},
+		{Version: "V4", Title: "latest", Direction: "down"},
+		{Version: "V5", Title: "broken"},
+		{Version: "V6", Title: "new"},
+	}
+	now := time.Now()
+	records := []*MigrationRecord{
+		{ID: 1, Version: "V1", Status: StatusSuccess, AppliedAt: now, ExecutionTime: 12},
+		{ID: 2, Version: "V4", Status: StatusSuccess, AppliedAt: now},
+		{ID: 3, Version: "V3", Status: StatusSuccess, AppliedAt: now},
+		{ID: 4, Version: "V0", Title: "removed", Status: StatusSuccess},
+		{ID: 5, Version: "V5", Status: StatusFailed},
+		// 回滚 V4 失败不影响 up 脚本的状态
+		{ID: 6, Version: "V4", Status: StatusFailed, Direction: DirectionDown},
+	}
+	got := map[string]*MigrationStatus{}
+	var order []string
+	for _, s := range statuses(files, records, "V4") {
+		got[s.Version] = s
+		order = append(order, s.Version)
+	}
+	expect := map[string]MigrationState{
+		"V0": StateMissing,
+		"V1": StateApplied,
+		"V2": StateIgnored,
+		"V3": StateOutOfOrder,
+		"V4": StateApplied,
+		"V5": StateFailed,
+		"V6": StatePending,
+	}
+	if len(got) != len(expect) || order[0] != "V0" || order[len(order)-1] != "V6" {
+		t.Fatalf("unexpected statuses %v", order)
+	}
+	for v, state := range expect {
+		if got[v].State != state {
+			t.Errorf("version %s: got %q, expect %q", v, got[v].State, state)
+		}
+	}
+	if got["V1"].AppliedAt == nil || got["V1"].ExecutionTime != 12 || got["V6"].AppliedAt != nil {
+		t.Errorf("applied_at and execution_time should come from history")
+	}
+}
@@ -0,0 +1,237 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/config"
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/jacktea/data-smith/pkg/utils"
+)
+
+// Execer 执行语句，*sql.DB、*sql.Conn 与 *sql.Tx 均实现
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// MigrationRecord schema_migrations 中的一条记录
+type MigrationRecord struct {
+	ID            int64
+	Version       string
+	Title         string
+	Script        string
+	Checksum      string
+	AppliedAt     time.Time
+	ExecutionTime int
+	Status        string
+	// FailedStatement 未使用事务执行的脚本失败时，失败语句的序号(从 1 开始)
+	FailedStatement int
+	// Direction 执行的脚本方向 up 或 down，旧版本写入的记录为 up
+	Direction string
+}
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	// StatusDeleted 已执行但本地脚本已删除，仍视为已执行
+	StatusDeleted = "deleted"
+	// StatusRolledBack 已通过 down 脚本回滚
+	StatusRolledBack = "rolled_back"
+)
+
+// 历史记录的脚本方向
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// historyColumn 迁移历史表中后续版本新增的列，已有的表在 EnsureTable 时补齐
+type historyColumn struct {
+	Name string
+	Type string
+}
+
+// MigrationStore 迁移历史表与重置语句的方言实现
+type MigrationStore interface {
+	// EnsureTable 创建迁移历史表
+	EnsureTable() error
+	// CurrentVersion 已执行的最高版本，不包括可重复脚本
+	CurrentVersion() (string, error)
+	// Records 按执行顺序返回全部历史记录
+	Records() ([]*MigrationRecord, error)
+	// UpdateChecksum 更新历史记录的校验和与脚本路径
+	UpdateChecksum(id int64, checksum, script string) error
+	// SetStatus 通过 e 更新历史记录的状态，e 可以是事务
+	SetStatus(e Execer, id int64, status string) error
+	// FailedStatement 版本在 direction 方向上最后一条记录为失败时返回失败语句的序号，否则返回 0
+	FailedStatement(version, direction string) (int, error)
+	// ClearFailed 删除执行失败的记录，返回删除的条数
+	ClearFailed() (int64, error)
+	// TransactionalDDL DDL 是否可以在事务中执行并回滚
+	TransactionalDDL() bool
+	// AdvisoryLock 在 c 上获取会话级的咨询锁，timeout 内未获取到时返回 false
+	AdvisoryLock(ctx context.Context, c *sql.Conn, timeout time.Duration) (bool, error)
+	// AdvisoryUnlock 释放 c 上的咨询锁
+	AdvisoryUnlock(ctx context.Context, c *sql.Conn) error
+	// EnsureLockTable 创建锁表，用于不支持咨询锁的环境
+	EnsureLockTable() error
+	// TryTableLock 尝试在锁表中写入锁记录，锁已被持有时返回错误
+	TryTableLock(owner string) error
+	// TableUnlock 删除锁表中的锁记录
+	TableUnlock() error
+	// TableLockHolder 锁表中锁的持有者与获取时间
+	TableLockHolder() (string, time.Time)
+	// Record 通过 e 写入一条历史记录，e 可以是事务
+	Record(e Execer, r *MigrationRecord) error
+	// ResetStatements 重置数据库的语句，需在同一连接上依次执行
+	ResetStatements() []string
+	// SplitStatements 将脚本拆分为可逐条执行的语句
+	SplitStatements(content string) []string
+}
+
+func NewMigrationStore(db conn.DBAdapter) (MigrationStore, error) {
+	base := historyStore{db: db.GetConn(), cfg: db.GetConfig()}
+	switch base.cfg.Type {
+	case consts.DBTypeMySQL:
+		base.bind = func(int) string { return "?" }
+		return &mysqlStore{base}, nil
+	case consts.DBTypePostgres:
+		base.bind = func(i int) string { return fmt.Sprintf("$%d", i) }
+		return &postgresStore{base}, nil
+	default:
+		return nil, errors.New("不支持的数据库类型")
+	}
+}
+
+// historyStore 各方言共用的历史表读写，bind 生成第 i 个参数的占位符
+type historyStore struct {
+	db   *sql.DB
+	cfg  *config.ConnConfig
+	bind func(i int) string
+}
+
+// ensureColumns 为旧版本创建的历史表补齐缺少的列
+func (s *historyStore) ensureColumns(columnsQuery string, columns []historyColumn) error {
+	rows, err := s.db.Query(columnsQuery)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[strings.ToLower(name)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, col := range columns {
+		if existing[col.Name] {
+			continue
+		}
+		if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE schema_migrations ADD COLUMN %s %s", col.Name, col.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentVersion 已执行的最高版本，版本号需按语义比较，不能直接在数据库中排序
+func (s *historyStore) CurrentVersion() (string, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT DISTINCT version FROM schema_migrations WHERE status IN (%s, %s) AND version <> ''", s.bind(1), s.bind(2)), StatusSuccess, StatusDeleted)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	current := ""
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return "", err
+		}
+		if utils.CompareVersion(version, current) > 0 {
+			current = version
+		}
+	}
+	return current, rows.Err()
+}
+
+func (s *historyStore) Records() ([]*MigrationRecord, error) {
+	rows, err := s.db.Query(`SELECT id, version, COALESCE(title, ''), COALESCE(script, ''), COALESCE(checksum, ''), applied_at, COALESCE(execution_time, 0), COALESCE(status, ''), COALESCE(failed_statement, 0), COALESCE(direction, 'up')
+		FROM schema_migrations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []*MigrationRecord
+	for rows.Next() {
+		r := &MigrationRecord{}
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Version, &r.Title, &r.Script, &r.Checksum, &appliedAt, &r.ExecutionTime, &r.Status, &r.FailedStatement, &r.Direction); err != nil {
+			return nil, err
+		}
+		r.AppliedAt = appliedAt.Time
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *historyStore) Record(e Execer, r *MigrationRecord) error {
+	status := r.Status
+	if status == "" {
+		status = StatusSuccess
+	}
+	direction := r.Direction
+	if direction == "" {
+		direction = DirectionUp
+	}
+	query := fmt.Sprintf("INSERT INTO schema_migrations (version, title, script, checksum, execution_time, status, failed_statement, direction) VALUES (%s)", s.placeholders(8))
+	_, err := e.ExecContext(context.Background(), query, r.Version, r.Title, r.Script, r.Checksum, r.ExecutionTime, status, r.FailedStatement, direction)
+	return err
+}
+
+func (s *historyStore) FailedStatement(version, direction string) (int, error) {
+	row := s.db.QueryRow(fmt.Sprintf("SELECT status, COALESCE(failed_statement, 0) FROM schema_migrations WHERE version = %s AND COALESCE(direction, 'up') = %s ORDER BY id DESC LIMIT 1", s.bind(1), s.bind(2)), version, direction)
+	var status string
+	var failed int
+	err := row.Scan(&status, &failed)
+	if err == sql.ErrNoRows || status != StatusFailed {
+		return 0, nil
+	}
+	return failed, err
+}
+
+func (s *historyStore) ClearFailed() (int64, error) {
+	res, err := s.db.Exec(fmt.Sprintf("DELETE FROM schema_migrations WHERE status = %s", s.bind(1)), StatusFailed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *historyStore) UpdateChecksum(id int64, checksum, script string) error {
+	_, err := s.db.Exec(fmt.Sprintf("UPDATE schema_migrations SET checksum = %s, script = %s WHERE id = %s", s.bind(1), s.bind(2), s.bind(3)), checksum, script, id)
+	return err
+}
+
+func (s *historyStore) SetStatus(e Execer, id int64, status string) error {
+	_, err := e.ExecContext(context.Background(), fmt.Sprintf("UPDATE schema_migrations SET status = %s WHERE id = %s", s.bind(1), s.bind(2)), status, id)
+	return err
+}
+
+func (s *historyStore) placeholders(n int) string {
+	binds := make([]string, n)
+	for i := range binds {
+		binds[i] = s.bind(i + 1)
+	}
+	return strings.Join(binds, ", ")
+}
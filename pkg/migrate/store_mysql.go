@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jacktea/data-smith/pkg/consts"
+)
+
+// mysqlHistoryColumns 建表语句之后新增的列
+var mysqlHistoryColumns = []historyColumn{
+	{Name: "script", Type: "VARCHAR(1024)"},
+	{Name: "checksum", Type: "VARCHAR(64)"},
+	{Name: "failed_statement", Type: "INT"},
+	{Name: "direction", Type: "VARCHAR(10)"},
+}
+
+type mysqlStore struct {
+	historyStore
+}
+
+func (s *mysqlStore) EnsureTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			version VARCHAR(255) NOT NULL,
+			title VARCHAR(255),
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			execution_time INT,
+			status VARCHAR(50) DEFAULT 'success'
+		)`)
+	if err != nil {
+		return err
+	}
+	return s.ensureColumns(`SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`, mysqlHistoryColumns)
+}
+
+// TransactionalDDL MySQL 的 DDL 会隐式提交事务
+func (s *mysqlStore) TransactionalDDL() bool {
+	return false
+}
+
+// lockName GET_LOCK 的锁名，MySQL 限制为 64 个字符
+func (s *mysqlStore) lockName() string {
+	name := "datasmith_migrate:" + s.cfg.DBName
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func (s *mysqlStore) AdvisoryLock(ctx context.Context, c *sql.Conn, timeout time.Duration) (bool, error) {
+	var got sql.NullInt64
+	if err := c.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", s.lockName(), int(timeout.Seconds())).Scan(&got); err != nil {
+		if mysqlLockUnsupported(err) {
+			return false, fmt.Errorf("%w: %v", ErrAdvisoryLockUnsupported, err)
+		}
+		return false, err
+	}
+	if !got.Valid {
+		return false, errors.New("GET_LOCK 返回 NULL")
+	}
+	return got.Int64 == 1, nil
+}
+
+// mysqlLockUnsupported GET_LOCK 被禁用(1235，如 Galera 严格模式)或不存在(1305)
+func mysqlLockUnsupported(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && (me.Number == 1235 || me.Number == 1305)
+}
+
+func (s *mysqlStore) AdvisoryUnlock(ctx context.Context, c *sql.Conn) error {
+	_, err := c.ExecContext(ctx, "DO RELEASE_LOCK(?)", s.lockName())
+	return err
+}
+
+// ResetStatements 删除并重建数据库，重建后切换回该库
+func (s *mysqlStore) ResetStatements() []string {
+	return []string{
+		fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", s.cfg.DBName),
+		fmt.Sprintf("CREATE DATABASE `%s`", s.cfg.DBName),
+		fmt.Sprintf("USE `%s`", s.cfg.DBName),
+	}
+}
+
+func (s *mysqlStore) SplitStatements(content string) []string {
+	return SplitStatements(content, consts.DBTypeMySQL)
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/lib/pq"
+)
+
+// postgresHistoryColumns 建表语句之后新增的列
+var postgresHistoryColumns = []historyColumn{
+	{Name: "script", Type: "VARCHAR(1024)"},
+	{Name: "checksum", Type: "VARCHAR(64)"},
+	{Name: "failed_statement", Type: "INTEGER"},
+	{Name: "direction", Type: "VARCHAR(10)"},
+}
+
+type postgresStore struct {
+	historyStore
+}
+
+func (s *postgresStore) EnsureTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			id SERIAL PRIMARY KEY,
+			version VARCHAR(255) NOT NULL,
+			title VARCHAR(255),
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			execution_time INTEGER,
+			status VARCHAR(50) DEFAULT 'success'
+		)`)
+	if err != nil {
+		return err
+	}
+	return s.ensureColumns(`SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`, postgresHistoryColumns)
+}
+
+func (s *postgresStore) TransactionalDDL() bool {
+	return true
+}
+
+// lockKey pg_advisory_lock 的键，由 schema 名称计算
+func (s *postgresStore) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("datasmith_migrate:" + s.cfg.DBName + "." + s.cfg.TableSchema))
+	return int64(h.Sum64())
+}
+
+// AdvisoryLock pg_advisory_lock 不支持超时，这里轮询 pg_try_advisory_lock
+func (s *postgresStore) AdvisoryLock(ctx context.Context, c *sql.Conn, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var ok bool
+		if err := c.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", s.lockKey()).Scan(&ok); err != nil {
+			if postgresLockUnsupported(err) {
+				return false, fmt.Errorf("%w: %v", ErrAdvisoryLockUnsupported, err)
+			}
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// postgresLockUnsupported 锁函数不存在(42883)或不支持(0A000)，如 Redshift 等兼容库
+func postgresLockUnsupported(err error) bool {
+	var pe *pq.Error
+	return errors.As(err, &pe) && (pe.Code == "42883" || pe.Code == "0A000")
+}
+
+func (s *postgresStore) AdvisoryUnlock(ctx context.Context, c *sql.Conn) error {
+	_, err := c.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", s.lockKey())
+	return err
+}
+
+// ResetStatements 删除并重建 schema
+func (s *postgresStore) ResetStatements() []string {
+	return []string{
+		fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", s.cfg.TableSchema),
+		fmt.Sprintf("CREATE SCHEMA %s", s.cfg.TableSchema),
+	}
+}
+
+func (s *postgresStore) SplitStatements(content string) []string {
+	return SplitStatements(content, consts.DBTypePostgres)
+}
@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/utils"
+)
+
+// IssueKind 校验发现的问题类型
+type IssueKind string
+
+const (
+	// IssueModified 已执行的脚本在执行后被修改
+	IssueModified IssueKind = "modified"
+	// IssueMissing 版本低于当前版本但从未执行的脚本，需使用 --out-of-order 执行
+	IssueMissing IssueKind = "missing"
+	// IssueUnknown 迁移历史中已执行、但本地找不到对应脚本的版本
+	IssueUnknown IssueKind = "unknown"
+	// IssueUnverified 历史记录中没有校验和，通常由旧版本写入
+	IssueUnverified IssueKind = "unverified"
+)
+
+// ValidationIssue 校验发现的一个问题
+type ValidationIssue struct {
+	Kind    IssueKind
+	Version string
+	Title   string
+	Script  string
+	// Applied 对应的历史记录，IssueMissing 时为 nil
+	Applied *MigrationRecord
+	// File 对应的本地脚本，IssueUnknown 时为 nil
+	File *MigrationFile
+}
+
+func (i *ValidationIssue) String() string {
+	switch i.Kind {
+	case IssueModified:
+		return fmt.Sprintf("脚本 %s 在执行后被修改 (记录校验和 %s, 当前校验和 %s)", i.Script, i.Applied.Checksum, i.File.Checksum())
+	case IssueMissing:
+		return fmt.Sprintf("脚本 %s 的版本 %s 低于当前版本且从未执行，可使用 --out-of-order 执行", i.Script, i.Version)
+	case IssueUnknown:
+		return fmt.Sprintf("已执行的版本 %s__%s 在本地找不到对应脚本", i.Version, i.Title)
+	default:
+		return fmt.Sprintf("版本 %s 的历史记录没有校验和", i.Version)
+	}
+}
+
+// ValidationResult 迁移脚本与迁移历史的校验结果
+type ValidationResult struct {
+	Issues []*ValidationIssue
+}
+
+// Failed 是否存在阻止迁移执行的问题，IssueMissing 与 IssueUnverified 只作提示
+func (r *ValidationResult) Failed() bool {
+	for _, issue := range r.Issues {
+		if issue.Kind == IssueModified || issue.Kind == IssueUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 返回指定类型的问题
+func (r *ValidationResult) Filter(kinds ...IssueKind) []*ValidationIssue {
+	var issues []*ValidationIssue
+	for _, issue := range r.Issues {
+		for _, k := range kinds {
+			if issue.Kind == k {
+				issues = append(issues, issue)
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// ValidateMigrations 比对本地脚本与迁移历史
+func ValidateMigrations(db conn.DBAdapter, files []*MigrationFile) (*ValidationResult, error) {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.Records()
+	if err != nil {
+		return nil, err
+	}
+	current, err := store.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	return validate(files, records, current), nil
+}
+
+func validate(files []*MigrationFile, records []*MigrationRecord, current string) *ValidationResult {
+	// 同一版本以最后一次成功执行的记录为准
+	applied := map[string]*MigrationRecord{}
+	var order []string
+	for _, r := range records {
+		if r.Version == "" || r.Direction == DirectionDown || (r.Status != StatusSuccess && r.Status != StatusDeleted) {
+			continue
+		}
+		if _, ok := applied[r.Version]; !ok {
+			order = append(order, r.Version)
+		}
+		applied[r.Version] = r
+	}
+
+	result := &ValidationResult{}
+	local := map[string]bool{}
+	for _, f := range files {
+		// 可重复脚本修改后会重新执行，不需要校验
+		if f.Direction == "down" || f.Repeatable {
+			continue
+		}
+		local[f.Version] = true
+		r, ok := applied[f.Version]
+		switch {
+		case !ok:
+			if current != "" && utils.CompareVersion(f.Version, current) < 0 {
+				result.Issues = append(result.Issues, &ValidationIssue{Kind: IssueMissing, Version: f.Version, Title: f.Title, Script: f.ScriptName(), File: f})
+			}
+		case r.Checksum == "":
+			result.Issues = append(result.Issues, &ValidationIssue{Kind: IssueUnverified, Version: f.Version, Title: f.Title, Script: f.ScriptName(), Applied: r, File: f})
+		case r.Checksum != f.Checksum():
+			result.Issues = append(result.Issues, &ValidationIssue{Kind: IssueModified, Version: f.Version, Title: f.Title, Script: f.ScriptName(), Applied: r, File: f})
+		}
+	}
+	for _, v := range order {
+		r := applied[v]
+		if !local[v] && r.Status != StatusDeleted {
+			result.Issues = append(result.Issues, &ValidationIssue{Kind: IssueUnknown, Version: r.Version, Title: r.Title, Script: r.Script, Applied: r})
+		}
+	}
+	return result
+}
+
+// RepairMigrations 修复迁移历史：按当前脚本更新被修改或缺少校验和的记录，
+// 将本地已删除脚本的记录标记为 deleted，IssueMissing 无法修复会被忽略
+func RepairMigrations(db conn.DBAdapter, issues []*ValidationIssue) error {
+	store, err := NewMigrationStore(db)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		switch issue.Kind {
+		case IssueModified, IssueUnverified:
+			err = store.UpdateChecksum(issue.Applied.ID, issue.File.Checksum(), issue.File.ScriptName())
+		case IssueUnknown:
+			err = store.SetStatus(db.GetConn(), issue.Applied.ID, StatusDeleted)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("修复版本 %s 失败: %w", issue.Version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import "testing"
+
+func TestChecksumNormalized(t *testing.T) {
+	a := &MigrationFile{Content: "CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1);\n"}
+	b := &MigrationFile{Content: "\ufeffCREATE TABLE t (id INT);  \r\nINSERT INTO t VALUES (1);\r\n\r\n"}
+	c := &MigrationFile{Content: "CREATE TABLE t (id BIGINT);\nINSERT INTO t VALUES (1);\n"}
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("line endings and trailing spaces should not change checksum")
+	}
+	if a.Checksum() == c.Checksum() {
+		t.Errorf("content change should change checksum")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v1 := &MigrationFile{Version: "V1", Title: "init", Script: "V1__init.sql", Content: "CREATE TABLE a (id INT);"}
+	v2 := &MigrationFile{Version: "V2", Title: "edited", Script: "V2__edited.sql", Content: "CREATE TABLE b (id BIGINT);"}
+	v3 := &MigrationFile{Version: "V3", Title: "skipped", Script: "V3__skipped.sql", Content: "CREATE TABLE c (id INT);"}
+	v3down := &MigrationFile{Version: "V3", Title: "skipped", Direction: "down", Content: "DROP TABLE c;"}
+	v6 := &MigrationFile{Version: "V6", Title: "legacy", Script: "V6__legacy.sql", Content: "CREATE TABLE f (id INT);"}
+	edited := &MigrationFile{Content: "CREATE TABLE b (id INT);"}
+
+	records := []*MigrationRecord{
+		{ID: 1, Version: "V1", Checksum: v1.Checksum(), Status: StatusSuccess},
+		{ID: 2, Version: "V2", Checksum: edited.Checksum(), Status: StatusSuccess},
+		{ID: 3, Version: "V4", Title: "removed", Checksum: "x", Status: StatusSuccess},
+		{ID: 4, Version: "V4.1", Title: "gone", Checksum: "x", Status: StatusDeleted},
+		{ID: 5, Version: "V6", Status: StatusSuccess},
+	}
+	result := validate([]*MigrationFile{v1, v2, v3down, v3, v6}, records, "V6")
+
+	got := map[string]IssueKind{}
+	for _, issue := range result.Issues {
+		got[issue.Version] = issue.Kind
+	}
+	expect := map[string]IssueKind{"V2": IssueModified, "V3": IssueMissing, "V4": IssueUnknown, "V6": IssueUnverified}
+	if len(got) != len(expect) {
+		t.Fatalf("got %v, expect %v", got, expect)
+	}
+	for v, k := range expect {
+		if got[v] != k {
+			t.Errorf("version %s: got %q, expect %q", v, got[v], k)
+		}
+	}
+	if !result.Failed() {
+		t.Errorf("modified and unknown scripts should fail validation")
+	}
+	if (&ValidationResult{Issues: result.Filter(IssueMissing, IssueUnverified)}).Failed() {
+		t.Errorf("missing and unverified scripts should not fail validation")
+	}
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+// RowChange 单行数据差异，Old 为目标端的行，New 为源端的行
+type RowChange struct {
+	Key     string      `json:"key"`
+	Old     conn.Record `json:"old,omitempty"`
+	New     conn.Record `json:"new,omitempty"`
+	Changed []string    `json:"changed,omitempty"`
+}
+
+// TableData 单张表的数据差异报告，实现 diff.DiffSink
+// 统计全部差异行数，但每类最多保留 Limit 行明细
+type TableData struct {
+	Table      string         `json:"table"`
+	Columns    []string       `json:"columns"`
+	KeyColumns []string       `json:"keyColumns,omitempty"`
+	Stats      diff.DiffStats `json:"stats"`
+	Added      []RowChange    `json:"added"`
+	Dropped    []RowChange    `json:"dropped"`
+	Modified   []RowChange    `json:"modified"`
+	// Truncated 差异行数超过 Limit，明细不完整
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	// Limit 每类差异保留的最大行数，不大于 0 时不限制
+	Limit int `json:"-"`
+}
+
+// NewTableData 创建表的数据差异报告，cols 为展示的列，keys 为定位行的键列
+func NewTableData(table string, cols, keys []string, limit int) *TableData {
+	return &TableData{Table: table, Columns: cols, KeyColumns: keys, Added: []RowChange{}, Dropped: []RowChange{}, Modified: []RowChange{}, Limit: limit}
+}
+
+// FromDataDiff 将内存中汇总的 DataDiff 转换为报告
+func FromDataDiff(table string, cols, keys []string, d *diff.DataDiff) *TableData {
+	t := NewTableData(table, cols, keys, 0)
+	for _, row := range d.Added {
+		t.Write(diff.DiffTypeAdd, row, nil)
+	}
+	for _, row := range d.Dropped {
+		t.Write(diff.DiffTypeDrop, nil, row)
+	}
+	for _, row := range d.Modified {
+		t.WriteChange(row.New, row.Old, row.Changed)
+	}
+	return t
+}
+
+func (t *TableData) Write(diffType diff.DiffType, srcRow, tgtRow conn.Record) error {
+	return t.add(diffType, srcRow, tgtRow, nil)
+}
+
+// WriteChange 实现 diff.ChangeSink，变化的列使用比对时比较器的结果，
+// 与比对一样忽略 ignoreColumns 并按列的比较方式判断
+func (t *TableData) WriteChange(srcRow, tgtRow conn.Record, changed []string) error {
+	return t.add(diff.DiffTypeModify, srcRow, tgtRow, changed)
+}
+
+func (t *TableData) add(diffType diff.DiffType, srcRow, tgtRow conn.Record, changed []string) error {
+	t.Stats.Add(diffType)
+	var rows *[]RowChange
+	switch diffType {
+	case diff.DiffTypeAdd:
+		rows = &t.Added
+	case diff.DiffTypeDrop:
+		rows = &t.Dropped
+	case diff.DiffTypeModify:
+		rows = &t.Modified
+	default:
+		return nil
+	}
+	if t.Limit > 0 && len(*rows) >= t.Limit {
+		t.Truncated = true
+		return nil
+	}
+	change := RowChange{Old: t.record(tgtRow), New: t.record(srcRow), Changed: changed}
+	if change.New != nil {
+		change.Key = t.rowKey(change.New)
+	} else {
+		change.Key = t.rowKey(change.Old)
+	}
+	*rows = append(*rows, change)
+	return nil
+}
+
+func (t *TableData) Close() error {
+	return nil
+}
+
+// record 复制行并转换为可读的值，避免引用驱动复用的缓冲区
+func (t *TableData) record(row conn.Record) conn.Record {
+	if row == nil {
+		return nil
+	}
+	r := make(conn.Record, len(row))
+	for k, v := range row {
+		r[k] = displayValue(v)
+	}
+	return r
+}
+
+// rowKey 以键列拼接行标识，没有键列时使用全部列
+func (t *TableData) rowKey(row conn.Record) string {
+	keys := t.KeyColumns
+	if len(keys) == 0 {
+		keys = t.Columns
+	}
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, formatValue(row[k]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,210 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+)
+
+// WriteSchema 按格式输出结构差异报告
+func WriteSchema(w io.Writer, format Format, r *SchemaReport) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, r)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"table", "type", "object", "name", "action", "old", "new"})
+		for _, t := range r.Tables {
+			if len(t.Changes) == 0 || t.Action == "DROP" {
+				cw.Write([]string{t.Table, t.Type, t.Type, t.Table, string(t.Action), "", ""})
+			}
+			if t.Action == "DROP" {
+				continue
+			}
+			for _, c := range t.Changes {
+				cw.Write([]string{t.Table, t.Type, c.Object, c.Name, string(c.Action), c.Old, c.New})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatMarkdown:
+		return writeSchemaMarkdown(w, r)
+	case FormatHTML:
+		return htmlTemplate.ExecuteTemplate(w, "schema", r)
+	}
+	return fmt.Errorf("format %s is not supported for reports", format)
+}
+
+// WriteData 按格式输出各表的数据差异报告
+func WriteData(w io.Writer, format Format, tables []*TableData) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, map[string]any{"tables": tables})
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"table", "action", "key", "column", "old", "new"})
+		for _, t := range tables {
+			for _, row := range t.Dropped {
+				cw.Write([]string{t.Table, "DROP", row.Key, "", t.rowValues(row.Old), ""})
+			}
+			for _, row := range t.Added {
+				cw.Write([]string{t.Table, "ADD", row.Key, "", "", t.rowValues(row.New)})
+			}
+			for _, row := range t.Modified {
+				for _, c := range row.Changed {
+					cw.Write([]string{t.Table, "MODIFY", row.Key, c, formatValue(row.Old[c]), formatValue(row.New[c])})
+				}
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatMarkdown:
+		return writeDataMarkdown(w, tables)
+	case FormatHTML:
+		return htmlTemplate.ExecuteTemplate(w, "data", tables)
+	}
+	return fmt.Errorf("format %s is not supported for reports", format)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (t *TableData) rowValues(row map[string]any) string {
+	parts := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		parts[i] = fmt.Sprintf("%s=%s", c, formatValue(row[c]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func writeSchemaMarkdown(w io.Writer, r *SchemaReport) error {
+	var b strings.Builder
+	b.WriteString("# Schema diff\n\n")
+	if r.IsEmpty() {
+		b.WriteString("No differences.\n")
+	}
+	for _, t := range r.Tables {
+		fmt.Fprintf(&b, "## %s `%s` (%s)\n\n", t.Type, t.Table, t.Action)
+		if len(t.Changes) == 0 || t.Action == "DROP" {
+			continue
+		}
+		b.WriteString("| Object | Name | Action | Old | New |\n|---|---|---|---|---|\n")
+		for _, c := range t.Changes {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", c.Object, mdCell(c.Name), c.Action, mdCell(c.Old), mdCell(c.New))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDataMarkdown(w io.Writer, tables []*TableData) error {
+	var b strings.Builder
+	b.WriteString("# Data diff\n\n| Table | Added | Dropped | Modified |\n|---|---|---|---|\n")
+	for _, t := range tables {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d |\n", mdCell(t.Table), t.Stats.Added, t.Stats.Dropped, t.Stats.Modified)
+	}
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\n## `%s`\n\n", t.Table)
+		if t.Error != "" {
+			fmt.Fprintf(&b, "Error: %s\n", mdCell(t.Error))
+			continue
+		}
+		if t.Stats.Total() == 0 {
+			b.WriteString("No differences.\n")
+			continue
+		}
+		if t.Truncated {
+			fmt.Fprintf(&b, "Only the first %d rows of each kind are listed.\n\n", t.Limit)
+		}
+		b.WriteString("| Action | Key | Changes |\n|---|---|---|\n")
+		for _, row := range t.Dropped {
+			fmt.Fprintf(&b, "| DROP | %s | %s |\n", mdCell(row.Key), mdCell(t.rowValues(row.Old)))
+		}
+		for _, row := range t.Added {
+			fmt.Fprintf(&b, "| ADD | %s | %s |\n", mdCell(row.Key), mdCell(t.rowValues(row.New)))
+		}
+		for _, row := range t.Modified {
+			changes := make([]string, len(row.Changed))
+			for i, c := range row.Changed {
+				changes[i] = fmt.Sprintf("%s: %s → %s", c, formatValue(row.Old[c]), formatValue(row.New[c]))
+			}
+			fmt.Fprintf(&b, "| MODIFY | %s | %s |\n", mdCell(row.Key), mdCell(strings.Join(changes, "; ")))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mdCell 转义 Markdown 表格单元格中的竖线与换行
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"value": func(row map[string]any, col string) string {
+		return formatValue(row[col])
+	},
+	"changed": func(row RowChange, col string) bool {
+		return slices.Contains(row.Changed, col)
+	},
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #24292f; }
+table { border-collapse: collapse; margin: 8px 0 24px; font-size: 13px; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; vertical-align: top; white-space: pre-wrap; }
+th { background: #f6f8fa; }
+tr.added td { background: #e6ffec; }
+tr.dropped td { background: #ffebe9; }
+td.changed { background: #fff8c5; }
+del { color: #cf222e; }
+ins { color: #1a7f37; text-decoration: none; }
+.muted { color: #57606a; }
+</style>
+</head>
+<body>
+{{end}}
+{{define "schema"}}{{template "head" "Schema diff"}}<h1>Schema diff</h1>
+{{if not .Tables}}<p class="muted">No differences.</p>{{end}}
+{{range .Tables}}<section>
+<h2>{{.Type}} {{.Table}} <span class="muted">({{.Action}})</span></h2>
+{{if and .Changes (ne .Action "DROP")}}<table>
+<tr><th>Object</th><th>Name</th><th>Action</th><th>Old</th><th>New</th></tr>
+{{range .Changes}}<tr><td>{{.Object}}</td><td>{{.Name}}</td><td>{{.Action}}</td><td><del>{{.Old}}</del></td><td><ins>{{.New}}</ins></td></tr>
+{{end}}</table>{{end}}
+</section>
+{{end}}</body>
+</html>
+{{end}}
+{{define "data"}}{{template "head" "Data diff"}}<h1>Data diff</h1>
+<table>
+<tr><th>Table</th><th>Added</th><th>Dropped</th><th>Modified</th></tr>
+{{range .}}<tr><td><a href="#{{.Table}}">{{.Table}}</a></td><td>{{.Stats.Added}}</td><td>{{.Stats.Dropped}}</td><td>{{.Stats.Modified}}</td></tr>
+{{end}}</table>
+{{range $t := .}}<section id="{{$t.Table}}">
+<h2>{{$t.Table}}</h2>
+{{if $t.Error}}<p>Error: {{$t.Error}}</p>
+{{else if not $t.Stats.Total}}<p class="muted">No differences.</p>
+{{else}}{{if $t.Truncated}}<p class="muted">Only the first {{$t.Limit}} rows of each kind are listed.</p>{{end}}
+<table>
+<tr><th></th>{{range $t.Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range $row := $t.Dropped}}<tr class="dropped"><td>DROP</td>{{range $c := $t.Columns}}<td>{{value $row.Old $c}}</td>{{end}}</tr>
+{{end}}{{range $row := $t.Added}}<tr class="added"><td>ADD</td>{{range $c := $t.Columns}}<td>{{value $row.New $c}}</td>{{end}}</tr>
+{{end}}{{range $row := $t.Modified}}<tr><td>MODIFY</td>{{range $c := $t.Columns}}{{if changed $row $c}}<td class="changed"><del>{{value $row.Old $c}}</del><br><ins>{{value $row.New $c}}</ins></td>{{else}}<td>{{value $row.New $c}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}</body>
+</html>
+{{end}}`))
@@ -0,0 +1,57 @@
+// Package report 将结构差异与数据差异渲染为便于阅读或工具处理的报告
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format 报告格式
+type Format string
+
+const (
+	// FormatSQL 默认格式，输出同步 SQL，不由本包渲染
+	FormatSQL      Format = "sql"
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// ParseFormat 解析格式名称，md 视为 markdown
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatSQL, FormatJSON, FormatCSV, FormatMarkdown, FormatHTML:
+		return f, nil
+	case "md":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected sql, json, csv, markdown or html", s)
+}
+
+// Ext 报告文件的扩展名
+func (f Format) Ext() string {
+	if f == FormatMarkdown {
+		return "md"
+	}
+	return string(f)
+}
+
+// displayValue 转换为可读的值：驱动返回的 []byte 转为文本，时间按 RFC3339 格式输出
+func displayValue(v any) any {
+	switch t := v.(type) {
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.Format("2006-01-02 15:04:05.999999999Z07:00")
+	}
+	return v
+}
+
+func formatValue(v any) string {
+	if v == nil {
+		return "NULL"
+	}
+	return fmt.Sprintf("%v", v)
+}
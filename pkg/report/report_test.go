@@ -0,0 +1,125 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+func testTableData() *TableData {
+	t := NewTableData("users", []string{"id", "name", "email"}, []string{"id"}, 0)
+	t.Write(diff.DiffTypeAdd, conn.Record{"id": 3, "name": []byte("carol"), "email": nil}, nil)
+	t.Write(diff.DiffTypeDrop, nil, conn.Record{"id": 4, "name": "dave", "email": "d@x"})
+	t.WriteChange(conn.Record{"id": 1, "name": "alice", "email": "a@new"}, conn.Record{"id": 1, "name": "alice", "email": "a@old"}, []string{"email"})
+	return t
+}
+
+func TestTableData(t *testing.T) {
+	data := testTableData()
+	if data.Stats.Total() != 3 {
+		t.Fatalf("unexpected stats %+v", data.Stats)
+	}
+	if row := data.Modified[0]; row.Key != "id=1" || len(row.Changed) != 1 || row.Changed[0] != "email" {
+		t.Errorf("unexpected modified row %+v", row)
+	}
+	if name := data.Added[0].New["name"]; name != "carol" {
+		t.Errorf("bytes not converted: %#v", name)
+	}
+
+	// 变化的列来自比对结果，不再按文本重新比较
+	fromDiff := FromDataDiff("users", []string{"id", "name"}, []string{"id"}, &diff.DataDiff{Modified: []diff.ModifiedRow{
+		{Old: conn.Record{"id": 1, "name": "alice"}, New: conn.Record{"id": 1, "name": "Alice"}},
+	}})
+	if changed := fromDiff.Modified[0].Changed; len(changed) != 0 {
+		t.Errorf("unexpected changed columns %v", changed)
+	}
+
+	limited := NewTableData("users", []string{"id"}, []string{"id"}, 1)
+	limited.Write(diff.DiffTypeAdd, conn.Record{"id": 1}, nil)
+	limited.Write(diff.DiffTypeAdd, conn.Record{"id": 2}, nil)
+	if len(limited.Added) != 1 || !limited.Truncated || limited.Stats.Added != 2 {
+		t.Errorf("limit not applied: %+v", limited)
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	tables := []*TableData{testTableData()}
+	tests := []struct {
+		format Format
+		expect []string
+	}{
+		{FormatCSV, []string{"users,MODIFY,id=1,email,a@old,a@new", "users,ADD,id=3,,,\"id=3, name=carol, email=NULL\""}},
+		{FormatMarkdown, []string{"| users | 1 | 1 | 1 |", "| MODIFY | id=1 | email: a@old → a@new |"}},
+		{FormatHTML, []string{`<td class="changed"><del>a@old</del><br><ins>a@new</ins></td>`, `<tr class="dropped"><td>DROP</td><td>4</td>`}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteData(&buf, tt.format, tables); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		for _, s := range tt.expect {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s: %q not found in\n%s", tt.format, s, buf.String())
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteData(&buf, FormatJSON, tables); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Tables []*TableData `json:"tables"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Tables) != 1 || decoded.Tables[0].Modified[0].New["email"] != "a@new" {
+		t.Errorf("unexpected json %s", buf.String())
+	}
+}
+
+func TestNewSchemaReport(t *testing.T) {
+	size := 64
+	d := &diff.SchemaDiff{
+		TablesDropped: []*conn.Table{{Name: "b_old", Type: conn.TableTypeTable}},
+		TablesModified: []*diff.TableDiff{{
+			Table:          &conn.Table{Name: "a_users", Type: conn.TableTypeTable},
+			ColumnsAdded:   []*conn.Column{{Name: "email", DataType: "varchar", CharMaxLen: &size, Nullable: true}},
+			IndexesDropped: []*conn.Index{{Name: "idx_name", Columns: []string{"name"}, Unique: true}},
+		}},
+	}
+	r := NewSchemaReport(d)
+	if len(r.Tables) != 2 || r.Tables[0].Table != "a_users" || len(r.Tables[0].Changes) != 2 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if c := r.Tables[0].Changes[0]; c.New != "varchar(64)" || c.Action != diff.DiffTypeAdd {
+		t.Errorf("unexpected column change %+v", c)
+	}
+	var buf bytes.Buffer
+	if err := WriteSchema(&buf, FormatMarkdown, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| index | idx_name | DROP | UNIQUE (name) |  |") {
+		t.Errorf("unexpected markdown\n%s", buf.String())
+	}
+}
+
+func TestSchemaReportIgnore(t *testing.T) {
+	r := &SchemaReport{Tables: []TableChanges{
+		{Table: "audit_log", Action: diff.DiffTypeAdd},
+		{Table: "users", Action: diff.DiffTypeModify, Changes: []Change{{Object: "column", Name: "updated_at"}, {Object: "column", Name: "email"}}},
+		{Table: "orders", Action: diff.DiffTypeModify, Changes: []Change{{Object: "index", Name: "idx_tmp_1"}}},
+	}}
+	got := r.Ignore([]string{"audit_*", "users.updated_at", "*.idx_tmp_*"})
+	if len(got.Tables) != 1 || got.Tables[0].Table != "users" || len(got.Tables[0].Changes) != 1 || got.Tables[0].Changes[0].Name != "email" {
+		t.Errorf("unexpected report %+v", got)
+	}
+	if len(r.Tables[1].Changes) != 2 {
+		t.Errorf("original report modified")
+	}
+}
@@ -0,0 +1,224 @@
+package report
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+// Change 单个对象的结构变更，Old 为目标端定义，New 为源端定义
+type Change struct {
+	Object string        `json:"object"`
+	Name   string        `json:"name"`
+	Action diff.DiffType `json:"action"`
+	Old    string        `json:"old,omitempty"`
+	New    string        `json:"new,omitempty"`
+}
+
+// TableChanges 单张表或视图的结构变更
+type TableChanges struct {
+	Table   string        `json:"table"`
+	Type    string        `json:"type"`
+	Action  diff.DiffType `json:"action"`
+	Changes []Change      `json:"changes,omitempty"`
+}
+
+// SchemaReport 结构差异报告，按表名排序
+type SchemaReport struct {
+	Tables []TableChanges `json:"tables"`
+}
+
+func NewSchemaReport(d *diff.SchemaDiff) *SchemaReport {
+	r := &SchemaReport{Tables: []TableChanges{}}
+	for _, tbl := range d.TablesAdded {
+		r.Tables = append(r.Tables, TableChanges{Table: tbl.Name, Type: tableType(tbl), Action: diff.DiffTypeAdd, Changes: tableDefinition(tbl)})
+	}
+	for _, tbl := range d.TablesDropped {
+		r.Tables = append(r.Tables, TableChanges{Table: tbl.Name, Type: tableType(tbl), Action: diff.DiffTypeDrop})
+	}
+	for _, td := range d.TablesModified {
+		r.Tables = append(r.Tables, TableChanges{Table: td.Table.Name, Type: tableType(td.Table), Action: diff.DiffTypeModify, Changes: tableChanges(td)})
+	}
+	slices.SortStableFunc(r.Tables, func(a, b TableChanges) int {
+		return strings.Compare(a.Table, b.Table)
+	})
+	return r
+}
+
+// IsEmpty 是否没有任何结构差异
+func (r *SchemaReport) IsEmpty() bool {
+	return len(r.Tables) == 0
+}
+
+func tableType(tbl *conn.Table) string {
+	if tbl.Type == conn.TableTypeView {
+		return "view"
+	}
+	return "table"
+}
+
+// tableDefinition 新增表的列定义
+func tableDefinition(tbl *conn.Table) []Change {
+	var changes []Change
+	if tbl.Type == conn.TableTypeView {
+		if tbl.ViewDefinition != nil {
+			changes = append(changes, Change{Object: "view", Name: tbl.Name, Action: diff.DiffTypeAdd, New: tbl.ViewDefinition.SelectStatement})
+		}
+		return changes
+	}
+	for _, col := range tbl.GetColumnsByPosition() {
+		changes = append(changes, Change{Object: "column", Name: col.Name, Action: diff.DiffTypeAdd, New: describeColumn(col)})
+	}
+	if tbl.PrimaryKey != nil {
+		changes = append(changes, Change{Object: "primary_key", Name: tbl.PrimaryKey.Name, Action: diff.DiffTypeAdd, New: describePrimaryKey(tbl.PrimaryKey)})
+	}
+	return changes
+}
+
+func tableChanges(td *diff.TableDiff) []Change {
+	var changes []Change
+	add := func(object, name string, action diff.DiffType, old, new string) {
+		changes = append(changes, Change{Object: object, Name: name, Action: action, Old: old, New: new})
+	}
+	for _, c := range td.ColumnsAdded {
+		add("column", c.Name, diff.DiffTypeAdd, "", describeColumn(c))
+	}
+	for _, c := range td.ColumnsDropped {
+		add("column", c.Name, diff.DiffTypeDrop, describeColumn(c), "")
+	}
+	for _, c := range td.ColumnsModified {
+		add("column", c.New.Name, diff.DiffTypeModify, describeColumn(c.Old), describeColumn(c.New))
+	}
+	if pk := td.PrimaryKeyChange; pk != nil {
+		action, name := diff.DiffTypeModify, ""
+		switch {
+		case pk.Old == nil:
+			action, name = diff.DiffTypeAdd, pk.New.Name
+		case pk.New == nil:
+			action, name = diff.DiffTypeDrop, pk.Old.Name
+		default:
+			name = pk.New.Name
+		}
+		add("primary_key", name, action, describePrimaryKey(pk.Old), describePrimaryKey(pk.New))
+	}
+	for _, idx := range td.IndexesAdded {
+		add("index", idx.Name, diff.DiffTypeAdd, "", describeIndex(idx))
+	}
+	for _, idx := range td.IndexesDropped {
+		add("index", idx.Name, diff.DiffTypeDrop, describeIndex(idx), "")
+	}
+	for _, idx := range td.IndexesModified {
+		add("index", idx.New.Name, diff.DiffTypeModify, describeIndex(idx.Old), describeIndex(idx.New))
+	}
+	for _, fk := range td.ForeignKeysAdded {
+		add("foreign_key", fk.Name, diff.DiffTypeAdd, "", describeForeignKey(fk))
+	}
+	for _, fk := range td.ForeignKeysDropped {
+		add("foreign_key", fk.Name, diff.DiffTypeDrop, describeForeignKey(fk), "")
+	}
+	for _, fk := range td.ForeignKeysModified {
+		add("foreign_key", fk.New.Name, diff.DiffTypeModify, describeForeignKey(fk.Old), describeForeignKey(fk.New))
+	}
+	if v := td.ViewDefinitionChange; v != nil {
+		add("view", td.Table.Name, diff.DiffTypeModify, v.Old.SelectStatement, v.New.SelectStatement)
+	}
+	return changes
+}
+
+func describeColumn(c *conn.Column) string {
+	var b strings.Builder
+	b.WriteString(c.DataType)
+	switch {
+	case c.CharMaxLen != nil:
+		fmt.Fprintf(&b, "(%d)", *c.CharMaxLen)
+	case c.NumericPrec != nil && c.NumericScale != nil:
+		fmt.Fprintf(&b, "(%d,%d)", *c.NumericPrec, *c.NumericScale)
+	}
+	if !c.Nullable {
+		b.WriteString(" NOT NULL")
+	}
+	if c.Default != nil {
+		b.WriteString(" DEFAULT " + *c.Default)
+	}
+	if c.Extra != "" {
+		b.WriteString(" " + c.Extra)
+	}
+	if c.Comment != nil && *c.Comment != "" {
+		fmt.Fprintf(&b, " COMMENT '%s'", *c.Comment)
+	}
+	return b.String()
+}
+
+func describePrimaryKey(pk *conn.PrimaryKey) string {
+	if pk == nil {
+		return ""
+	}
+	return "(" + strings.Join(pk.Columns, ", ") + ")"
+}
+
+func describeIndex(idx *conn.Index) string {
+	var b strings.Builder
+	if idx.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	if idx.Method != "" {
+		b.WriteString(idx.Method + " ")
+	}
+	if idx.Expression != nil {
+		b.WriteString("(" + *idx.Expression + ")")
+	} else {
+		b.WriteString("(" + strings.Join(idx.Columns, ", ") + ")")
+	}
+	if idx.Where != nil {
+		b.WriteString(" WHERE " + *idx.Where)
+	}
+	return b.String()
+}
+
+func describeForeignKey(fk *conn.ForeignKey) string {
+	s := fmt.Sprintf("(%s) REFERENCES %s(%s)", strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "))
+	if fk.OnDelete != "" {
+		s += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		s += " ON UPDATE " + fk.OnUpdate
+	}
+	return s
+}
+
+// Ignore 返回去掉忽略项后的报告，pattern 使用 path.Match 语法：
+// "audit_*" 忽略整张表，"users.updated_at"、"*.idx_tmp_*" 忽略表中同名的列、索引或约束
+func (r *SchemaReport) Ignore(patterns []string) *SchemaReport {
+	if len(patterns) == 0 {
+		return r
+	}
+	filtered := &SchemaReport{Tables: []TableChanges{}}
+	for _, t := range r.Tables {
+		if matchAny(patterns, t.Table) {
+			continue
+		}
+		changes := slices.DeleteFunc(slices.Clone(t.Changes), func(c Change) bool {
+			return matchAny(patterns, t.Table+"."+c.Name)
+		})
+		// 修改的表的变更全部被忽略时不再视为差异
+		if t.Action == diff.DiffTypeModify && len(changes) == 0 {
+			continue
+		}
+		t.Changes = changes
+		filtered.Tables = append(filtered.Tables, t)
+	}
+	return filtered
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
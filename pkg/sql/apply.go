@@ -0,0 +1,292 @@
+package sql
+
+import (
+	"bufio"
+	"context"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/logger"
+)
+
+const defaultApplyChunkSize = 1000
+
+// ApplyPhase 限定执行的差异类型。多表存在外键时分两遍执行：
+// 先按子表到父表的顺序删除，再按父表到子表的顺序插入与更新
+type ApplyPhase int
+
+const (
+	// ApplyAll 执行全部差异
+	ApplyAll ApplyPhase = iota
+	// ApplyDeletes 只执行删除
+	ApplyDeletes
+	// ApplyWrites 只执行插入与更新
+	ApplyWrites
+)
+
+// ApplyOptions 差异直接应用到目标库的参数
+type ApplyOptions struct {
+	// UpdateCols 更新时写入的列，为空时更新全部非键列
+	UpdateCols []string
+	// KeyColumns 定位行使用的键列，为空时使用表的主键或非空唯一索引
+	KeyColumns []string
+	// Upsert 为 true 时新增与修改的行均使用插入或更新语句
+	Upsert bool
+	// ChunkSize 每个事务包含的最大差异行数
+	ChunkSize int
+	// DisableFKChecks 为 true 时在事务内关闭外键检查
+	DisableFKChecks bool
+	// Phase 执行的差异类型，默认全部执行
+	Phase ApplyPhase
+	// TmpDir 差异的暂存目录，为空时使用系统临时目录
+	TmpDir string
+}
+
+// ApplyStats 实际影响的行数
+type ApplyStats struct {
+	Deleted  int64
+	Inserted int64
+	Updated  int64
+}
+
+// ApplyDiffSink 将差异以参数化语句分批在事务中执行到目标库
+// 比对过程中差异只写入暂存文件，Close 时再分批执行，避免修改仍在分页读取的目标表；
+// 每批内按 DELETE、INSERT、UPDATE 的顺序执行，避免唯一约束冲突
+type ApplyDiffSink struct {
+	db      *dbsql.DB
+	dialect IDialect
+	tbl     *conn.Table
+	opts    ApplyOptions
+	spool   *diffSpool
+
+	deletes []conn.Record
+	inserts []conn.Record
+	updates []conn.Record
+	stats   ApplyStats
+}
+
+func NewApplyDiffSink(db *dbsql.DB, dialect IDialect, tbl *conn.Table, opts ApplyOptions) *ApplyDiffSink {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultApplyChunkSize
+	}
+	if len(opts.KeyColumns) > 0 {
+		keyTbl := *tbl
+		keyTbl.PrimaryKey = &conn.PrimaryKey{Columns: opts.KeyColumns}
+		tbl = &keyTbl
+	}
+	return &ApplyDiffSink{db: db, dialect: dialect, tbl: tbl, opts: opts, spool: &diffSpool{dir: opts.TmpDir}}
+}
+
+func (s *ApplyDiffSink) Write(diffType diff.DiffType, srcRow, tgtRow conn.Record) error {
+	if (s.opts.Phase == ApplyDeletes && diffType != diff.DiffTypeDrop) ||
+		(s.opts.Phase == ApplyWrites && diffType == diff.DiffTypeDrop) {
+		return nil
+	}
+	row := srcRow
+	if diffType == diff.DiffTypeDrop {
+		row = tgtRow
+	}
+	return s.spool.Write(diffType, row)
+}
+
+// Stats 返回已提交的影响行数
+func (s *ApplyDiffSink) Stats() ApplyStats {
+	return s.stats
+}
+
+// Close 分批执行暂存的差异
+func (s *ApplyDiffSink) Close() error {
+	defer s.spool.Remove()
+	err := s.spool.Replay(func(diffType diff.DiffType, row conn.Record) error {
+		switch diffType {
+		case diff.DiffTypeDrop:
+			s.deletes = append(s.deletes, row)
+		case diff.DiffTypeAdd:
+			s.inserts = append(s.inserts, row)
+		case diff.DiffTypeModify:
+			s.updates = append(s.updates, row)
+		}
+		if len(s.deletes)+len(s.inserts)+len(s.updates) >= s.opts.ChunkSize {
+			return s.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// flush 在一个事务中执行缓存的差异，失败时回滚
+func (s *ApplyDiffSink) flush() error {
+	if len(s.deletes)+len(s.inserts)+len(s.updates) == 0 {
+		return nil
+	}
+	// 使用独占连接，关闭外键检查等会话级设置只影响该连接
+	ctx := context.Background()
+	c, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, enableFK := s.dialect.GenerateDisableFKChecksSql(); s.opts.DisableFKChecks && enableFK != "" {
+		// 无论事务成功与否都在归还连接前恢复，恢复失败时丢弃该连接
+		defer func() {
+			if _, err := c.ExecContext(ctx, enableFK); err != nil {
+				c.Raw(func(any) error { return driver.ErrBadConn })
+			}
+		}()
+	}
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var chunk ApplyStats
+	if err := s.execChunk(tx, &chunk); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("apply diff to table %s: %w", s.tbl.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.stats.Deleted += chunk.Deleted
+	s.stats.Inserted += chunk.Inserted
+	s.stats.Updated += chunk.Updated
+	logger.Infof("表 %s 已应用差异: 删除 %d, 插入 %d, 更新 %d", s.tbl.Name, s.stats.Deleted, s.stats.Inserted, s.stats.Updated)
+	s.deletes, s.inserts, s.updates = s.deletes[:0], s.inserts[:0], s.updates[:0]
+	return nil
+}
+
+func (s *ApplyDiffSink) execChunk(tx *dbsql.Tx, chunk *ApplyStats) error {
+	if s.opts.DisableFKChecks {
+		disable, _ := s.dialect.GenerateDisableFKChecksSql()
+		if _, err := tx.Exec(disable); err != nil {
+			return err
+		}
+	}
+	query, cols := s.dialect.GenerateDeleteStmt(s.tbl)
+	if err := s.execRows(tx, query, cols, s.deletes, &chunk.Deleted); err != nil {
+		return err
+	}
+	if s.opts.Upsert {
+		query, cols = s.dialect.GenerateUpsertStmt(s.tbl, s.opts.UpdateCols)
+	} else {
+		query, cols = s.dialect.GenerateInsertStmt(s.tbl)
+	}
+	if err := s.execRows(tx, query, cols, s.inserts, &chunk.Inserted); err != nil {
+		return err
+	}
+	if s.opts.Upsert {
+		query, cols = s.dialect.GenerateUpsertStmt(s.tbl, s.opts.UpdateCols)
+	} else {
+		query, cols = s.dialect.GenerateUpdateStmt(s.tbl, s.opts.UpdateCols)
+	}
+	return s.execRows(tx, query, cols, s.updates, &chunk.Updated)
+}
+
+func (s *ApplyDiffSink) execRows(tx *dbsql.Tx, query string, cols []string, rows []conn.Record, affected *int64) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		args := make([]any, len(cols))
+		for i, c := range cols {
+			args[i] = paramValue(s.tbl.GetColumn(c), row[c])
+		}
+		res, err := stmt.Exec(args...)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			*affected += n
+		}
+	}
+	return nil
+}
+
+func init() {
+	// 驱动返回的时间值以接口类型暂存
+	gob.Register(time.Time{})
+}
+
+// spooledDiff 暂存的一行差异
+type spooledDiff struct {
+	Type diff.DiffType
+	Row  conn.Record
+}
+
+// diffSpool 按需创建的差异暂存文件，以 gob 编码保留驱动返回的值类型
+type diffSpool struct {
+	dir  string
+	file *os.File
+	w    *bufio.Writer
+	enc  *gob.Encoder
+}
+
+func (f *diffSpool) Write(diffType diff.DiffType, row conn.Record) error {
+	if f.file == nil {
+		file, err := os.CreateTemp(f.dir, ".datasmith_apply_*.gob")
+		if err != nil {
+			return err
+		}
+		f.file = file
+		f.w = bufio.NewWriter(file)
+		f.enc = gob.NewEncoder(f.w)
+	}
+	return f.enc.Encode(spooledDiff{Type: diffType, Row: row})
+}
+
+// Replay 按写入顺序读取暂存的差异
+func (f *diffSpool) Replay(fn func(diff.DiffType, conn.Record) error) error {
+	if f.file == nil {
+		return nil
+	}
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bufio.NewReader(f.file))
+	for {
+		var d spooledDiff
+		if err := dec.Decode(&d); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(d.Type, d.Row); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *diffSpool) Remove() {
+	if f.file == nil {
+		return
+	}
+	f.file.Close()
+	os.Remove(f.file.Name())
+	f.file = nil
+}
+
+// paramValue 转换参数值：驱动以 []byte 返回的非二进制列按字符串传递，
+// 否则 PostgreSQL 驱动会将其作为 bytea 写入
+func paramValue(col *conn.Column, val any) any {
+	if b, ok := val.([]byte); ok && col.Kind() != conn.ColumnKindBinary {
+		return string(b)
+	}
+	return val
+}
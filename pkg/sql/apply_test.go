@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+func TestGenerateStmt(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":  {Name: "id", DataType: "integer", Position: 1},
+			"val": {Name: "val", DataType: "text", Position: 2, Nullable: true},
+		},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+	}
+	noKey := &conn.Table{Name: "t", Columns: tbl.Columns}
+	type stmtFunc func(d IDialect) (string, []string)
+	tests := []struct {
+		name   string
+		dbType consts.DBType
+		gen    stmtFunc
+		query  string
+		cols   []string
+	}{
+		{"pg insert", consts.DBTypePostgres, func(d IDialect) (string, []string) { return d.GenerateInsertStmt(tbl) },
+			`INSERT INTO t ("id", "val") VALUES ($1, $2)`, []string{"id", "val"}},
+		{"pg update", consts.DBTypePostgres, func(d IDialect) (string, []string) { return d.GenerateUpdateStmt(tbl, []string{"val"}) },
+			`UPDATE t SET "val" = $1 WHERE "id" = $2`, []string{"val", "id"}},
+		{"pg delete", consts.DBTypePostgres, func(d IDialect) (string, []string) { return d.GenerateDeleteStmt(tbl) },
+			`DELETE FROM t WHERE "id" = $1`, []string{"id"}},
+		{"pg delete without key", consts.DBTypePostgres, func(d IDialect) (string, []string) { return d.GenerateDeleteStmt(noKey) },
+			`DELETE FROM t WHERE ctid = (SELECT ctid FROM t WHERE "id" IS NOT DISTINCT FROM $1 AND "val" IS NOT DISTINCT FROM $2 LIMIT 1)`, []string{"id", "val"}},
+		{"pg upsert", consts.DBTypePostgres, func(d IDialect) (string, []string) { return d.GenerateUpsertStmt(tbl, nil) },
+			`INSERT INTO t ("id", "val") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "val" = EXCLUDED."val"`, []string{"id", "val"}},
+		{"mysql update", consts.DBTypeMySQL, func(d IDialect) (string, []string) { return d.GenerateUpdateStmt(tbl, nil) },
+			"UPDATE `t` SET `val` = ? WHERE `id` = ?", []string{"val", "id"}},
+		{"mysql delete without key", consts.DBTypeMySQL, func(d IDialect) (string, []string) { return d.GenerateDeleteStmt(noKey) },
+			"DELETE FROM `t` WHERE `id` <=> ? AND `val` <=> ? LIMIT 1", []string{"id", "val"}},
+		{"mysql upsert", consts.DBTypeMySQL, func(d IDialect) (string, []string) { return d.GenerateUpsertStmt(tbl, nil) },
+			"INSERT INTO `t` (`id`, `val`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `val` = VALUES(`val`)", []string{"id", "val"}},
+	}
+	for _, tt := range tests {
+		query, cols := tt.gen(NewDialect(tt.dbType))
+		if query != tt.query {
+			t.Errorf("%s: got %q, expect %q", tt.name, query, tt.query)
+		}
+		if !reflect.DeepEqual(cols, tt.cols) {
+			t.Errorf("%s: got cols %v, expect %v", tt.name, cols, tt.cols)
+		}
+	}
+}
+
+func TestApplyDiffSinkPhase(t *testing.T) {
+	tbl := &conn.Table{
+		Name:       "t",
+		Columns:    map[string]*conn.Column{"id": {Name: "id", DataType: "integer", Position: 1}},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+	}
+	spooled := func(phase ApplyPhase) []diff.DiffType {
+		// ChunkSize 为 1 时写入阶段也不执行语句，db 为 nil 不会被使用
+		s := NewApplyDiffSink(nil, NewDialect(consts.DBTypeMySQL), tbl, ApplyOptions{Phase: phase, ChunkSize: 1, TmpDir: t.TempDir()})
+		defer s.spool.Remove()
+		s.Write(diff.DiffTypeDrop, nil, conn.Record{"id": 1})
+		s.Write(diff.DiffTypeAdd, conn.Record{"id": 2}, nil)
+		s.Write(diff.DiffTypeModify, conn.Record{"id": 3}, conn.Record{"id": 3})
+		var types []diff.DiffType
+		s.spool.Replay(func(diffType diff.DiffType, _ conn.Record) error {
+			types = append(types, diffType)
+			return nil
+		})
+		return types
+	}
+	if got := spooled(ApplyDeletes); !reflect.DeepEqual(got, []diff.DiffType{diff.DiffTypeDrop}) {
+		t.Errorf("deletes phase spooled %v", got)
+	}
+	if got := spooled(ApplyWrites); !reflect.DeepEqual(got, []diff.DiffType{diff.DiffTypeAdd, diff.DiffTypeModify}) {
+		t.Errorf("writes phase spooled %v", got)
+	}
+	if got := spooled(ApplyAll); len(got) != 3 {
+		t.Errorf("all phase spooled %v", got)
+	}
+}
+
+func TestDiffSpoolKeepsValueTypes(t *testing.T) {
+	spool := &diffSpool{dir: t.TempDir()}
+	defer spool.Remove()
+	row := conn.Record{"id": int64(1), "name": []byte("a"), "at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "memo": nil, "ok": true}
+	if err := spool.Write(diff.DiffTypeAdd, row); err != nil {
+		t.Fatal(err)
+	}
+	var got []conn.Record
+	if err := spool.Replay(func(_ diff.DiffType, r conn.Record) error {
+		got = append(got, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], row) {
+		t.Errorf("got %#v, expect %#v", got, row)
+	}
+}
@@ -33,6 +33,85 @@ type IDialect interface {
 	// 更新语句
 	GenerateUpdateSql(tbl *conn.Table, row conn.Record, updateCols []string) string
 
+	// GenerateUpsertSql 生成插入或更新语句，键冲突时更新指定列，没有行键时退化为插入语句
+	// 参数：
+	// tbl: 表
+	// row: 行数据
+	// updateCols: 冲突时更新的列，为空时更新全部非键列
+	// 返回：
+	// 插入或更新语句
+	GenerateUpsertSql(tbl *conn.Table, row conn.Record, updateCols []string) string
+
+	// GenerateInsertStmt 生成参数化插入语句
+	// 参数：
+	// tbl: 表
+	// 返回：
+	// 插入语句与参数对应的列
+	GenerateInsertStmt(tbl *conn.Table) (string, []string)
+
+	// GenerateDeleteStmt 生成参数化删除语句，没有行键时按整行匹配且只删除一行
+	// 参数：
+	// tbl: 表
+	// 返回：
+	// 删除语句与参数对应的列
+	GenerateDeleteStmt(tbl *conn.Table) (string, []string)
+
+	// GenerateUpdateStmt 生成参数化更新语句
+	// 参数：
+	// tbl: 表
+	// updateCols: 更新列，为空时更新全部非键列
+	// 返回：
+	// 更新语句与参数对应的列
+	GenerateUpdateStmt(tbl *conn.Table, updateCols []string) (string, []string)
+
+	// GenerateUpsertStmt 生成参数化插入或更新语句
+	// 参数：
+	// tbl: 表
+	// updateCols: 冲突时更新的列，为空时更新全部非键列
+	// 返回：
+	// 插入或更新语句与参数对应的列
+	GenerateUpsertStmt(tbl *conn.Table, updateCols []string) (string, []string)
+
+	// GenerateDisableFKChecksSql 生成在事务内关闭与恢复外键检查的语句，恢复语句为空时表示随事务结束自动恢复
+	// 返回：
+	// 关闭语句与恢复语句
+	GenerateDisableFKChecksSql() (string, string)
+
+	// GenerateBatchInsertSql 生成多行插入语句 INSERT ... VALUES (...), (...)
+	// 参数：
+	// tbl: 表
+	// rows: 行数据
+	// 返回：
+	// 插入语句
+	GenerateBatchInsertSql(tbl *conn.Table, rows []conn.Record) string
+
+	// GenerateBatchDeleteSql 生成按键分组的删除语句 DELETE ... WHERE pk IN (...)
+	// 没有行键或键值包含 NULL 的行逐行生成删除语句
+	// 参数：
+	// tbl: 表
+	// rows: 行数据
+	// 返回：
+	// 删除语句，多条语句以换行分隔
+	GenerateBatchDeleteSql(tbl *conn.Table, rows []conn.Record) string
+
+	// GenerateCopySql 生成批量导入语句
+	// PostgreSQL 为 COPY ... FROM stdin(dataFile 为空时，数据紧随其后并以 \. 结束)或 COPY ... FROM 'dataFile'，
+	// MySQL 为 LOAD DATA LOCAL INFILE 'dataFile'，dataFile 不能为空
+	// 参数：
+	// tbl: 表
+	// dataFile: 数据文件
+	// 返回：
+	// 导入语句
+	GenerateCopySql(tbl *conn.Table, dataFile string) string
+
+	// GenerateCopyData 生成批量导入的数据，按列位置输出制表符分隔的文本，NULL 为 \N
+	// 参数：
+	// tbl: 表
+	// rows: 行数据
+	// 返回：
+	// 数据行，每行以换行结束
+	GenerateCopyData(tbl *conn.Table, rows []conn.Record) string
+
 	// GenerateCreateIndexSql 生成创建索引语句
 	// 参数：
 	// t: 表
@@ -1,9 +1,11 @@
 package mysql
 
 import (
+	"encoding/hex"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/jacktea/data-smith/pkg/conn"
 	"github.com/jacktea/data-smith/pkg/utils"
@@ -21,31 +23,45 @@ func NewMySQLDialect() *mysqlDialect {
 
 func (d *mysqlDialect) GenerateInsertSql(tbl *conn.Table, row conn.Record) string {
 	var colNames, values []string
-	for _, col := range tbl.Columns {
+	for _, col := range tbl.GetColumnsByPosition() {
 		colNames = append(colNames, fmt.Sprintf("`%s`", col.Name))
 		val := row[col.Name]
-		values = append(values, d.escapedValue(col.DataType, val))
+		values = append(values, d.escapedValue(col, val))
 	}
 	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s);", tbl.Name, strings.Join(colNames, ", "), strings.Join(values, ", "))
 }
 
 func (d *mysqlDialect) GenerateDeleteSql(tbl *conn.Table, row conn.Record) string {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		// 没有行键时按整行匹配，每条语句只删除一行，重复行由多条语句逐条删除
+		where := d.rowConditions(tbl, row, tbl.GetColumnNamesByPosition())
+		return fmt.Sprintf("DELETE FROM `%s` WHERE %s LIMIT 1;", tbl.Name, strings.Join(where, " AND "))
+	}
+	return fmt.Sprintf("DELETE FROM `%s` WHERE %s;", tbl.Name, strings.Join(d.rowConditions(tbl, row, keys), " AND "))
+}
+
+// rowConditions 生成按列值定位行的条件
+func (d *mysqlDialect) rowConditions(tbl *conn.Table, row conn.Record, cols []string) []string {
 	var where []string
-	for _, k := range tbl.PrimaryKey.Columns {
+	for _, k := range cols {
 		col := tbl.Columns[k]
 		val := row[k]
 		if val == nil {
 			where = append(where, fmt.Sprintf("`%s` IS NULL", k))
+		} else if col.Kind() == conn.ColumnKindJSON {
+			// JSON 列与字符串比较时字符串会被视为 JSON 字符串值，按文本比较
+			where = append(where, fmt.Sprintf("CAST(`%s` AS CHAR) = %v", k, d.escapedValue(col, val)))
 		} else {
-			where = append(where, fmt.Sprintf("`%s` = %v", k, d.escapedValue(col.DataType, val)))
+			where = append(where, fmt.Sprintf("`%s` = %v", k, d.escapedValue(col, val)))
 		}
 	}
-	return fmt.Sprintf("DELETE FROM `%s` WHERE %s;", tbl.Name, strings.Join(where, " AND "))
+	return where
 }
 
 func (d *mysqlDialect) GenerateUpdateSql(tbl *conn.Table, row conn.Record, updateCols []string) string {
 	var set, where []string
-	pks := tbl.PrimaryKey.Columns
+	pks := tbl.GetRowKeyColumns()
 	if len(updateCols) == 0 {
 		updateCols = tbl.GetColumns()
 	}
@@ -55,18 +71,184 @@ func (d *mysqlDialect) GenerateUpdateSql(tbl *conn.Table, row conn.Record, updat
 		}
 		col := tbl.Columns[c]
 		val := row[c]
-		set = append(set, fmt.Sprintf("`%s` = %s", c, d.escapedValue(col.DataType, val)))
+		set = append(set, fmt.Sprintf("`%s` = %s", c, d.escapedValue(col, val)))
 	}
-	for _, k := range pks {
-		col := tbl.Columns[k]
-		val := row[k]
-		if val == nil {
-			where = append(where, fmt.Sprintf("`%s` IS NULL", k))
+	where = d.rowConditions(tbl, row, pks)
+	return fmt.Sprintf("UPDATE `%s` SET %s WHERE %s;", tbl.Name, strings.Join(set, ", "), strings.Join(where, " AND "))
+}
+
+func (d *mysqlDialect) GenerateUpsertSql(tbl *conn.Table, row conn.Record, updateCols []string) string {
+	var colNames, values []string
+	for _, col := range tbl.GetColumnsByPosition() {
+		colNames = append(colNames, fmt.Sprintf("`%s`", col.Name))
+		values = append(values, d.escapedValue(col, row[col.Name]))
+	}
+	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)%s;", tbl.Name, strings.Join(colNames, ", "), strings.Join(values, ", "), d.duplicateKeyClause(tbl, updateCols))
+}
+
+// duplicateKeyClause 生成 ON DUPLICATE KEY UPDATE 子句，没有行键时返回空
+func (d *mysqlDialect) duplicateKeyClause(tbl *conn.Table, updateCols []string) string {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		return ""
+	}
+	if len(updateCols) == 0 {
+		updateCols = tbl.GetColumnNamesByPosition()
+	}
+	var set []string
+	for _, c := range updateCols {
+		if slices.Contains(keys, c) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("`%s` = VALUES(`%s`)", c, c))
+	}
+	if len(set) == 0 {
+		// 没有需要更新的列时保持原值，仅忽略键冲突
+		set = append(set, fmt.Sprintf("`%s` = `%s`", keys[0], keys[0]))
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (d *mysqlDialect) GenerateInsertStmt(tbl *conn.Table) (string, []string) {
+	cols := tbl.GetColumnNamesByPosition()
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)", tbl.Name, utils.JoinWrap(cols, "`", ", "), params), cols
+}
+
+func (d *mysqlDialect) GenerateDeleteStmt(tbl *conn.Table) (string, []string) {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		cols := tbl.GetColumnNamesByPosition()
+		return fmt.Sprintf("DELETE FROM `%s` WHERE %s LIMIT 1", tbl.Name, d.paramConditions(tbl, cols, true)), cols
+	}
+	return fmt.Sprintf("DELETE FROM `%s` WHERE %s", tbl.Name, d.paramConditions(tbl, keys, false)), keys
+}
+
+func (d *mysqlDialect) GenerateUpdateStmt(tbl *conn.Table, updateCols []string) (string, []string) {
+	keys := tbl.GetRowKeyColumns()
+	if len(updateCols) == 0 {
+		updateCols = tbl.GetColumnNamesByPosition()
+	}
+	var set, params []string
+	for _, c := range updateCols {
+		if slices.Contains(keys, c) {
+			continue
+		}
+		params = append(params, c)
+		set = append(set, fmt.Sprintf("`%s` = ?", c))
+	}
+	return fmt.Sprintf("UPDATE `%s` SET %s WHERE %s", tbl.Name, strings.Join(set, ", "), d.paramConditions(tbl, keys, false)), append(params, keys...)
+}
+
+func (d *mysqlDialect) GenerateUpsertStmt(tbl *conn.Table, updateCols []string) (string, []string) {
+	insert, cols := d.GenerateInsertStmt(tbl)
+	return insert + d.duplicateKeyClause(tbl, updateCols), cols
+}
+
+func (d *mysqlDialect) GenerateDisableFKChecksSql() (string, string) {
+	// 会话级变量，调用方需在归还连接前恢复，避免影响连接池中的后续使用
+	return "SET FOREIGN_KEY_CHECKS = 0", "SET FOREIGN_KEY_CHECKS = 1"
+}
+
+// paramConditions 生成参数化的定位条件，nullSafe 为 true 时使用 <=> 匹配 NULL
+func (d *mysqlDialect) paramConditions(tbl *conn.Table, cols []string, nullSafe bool) string {
+	op := "="
+	if nullSafe {
+		op = "<=>"
+	}
+	where := make([]string, len(cols))
+	for i, c := range cols {
+		if tbl.GetColumn(c).Kind() == conn.ColumnKindJSON {
+			where[i] = fmt.Sprintf("CAST(`%s` AS CHAR) %s ?", c, op)
 		} else {
-			where = append(where, fmt.Sprintf("`%s` = %v", k, d.escapedValue(col.DataType, val)))
+			where[i] = fmt.Sprintf("`%s` %s ?", c, op)
 		}
 	}
-	return fmt.Sprintf("UPDATE `%s` SET %s WHERE %s;", tbl.Name, strings.Join(set, ", "), strings.Join(where, " AND "))
+	return strings.Join(where, " AND ")
+}
+
+func (d *mysqlDialect) GenerateBatchInsertSql(tbl *conn.Table, rows []conn.Record) string {
+	cols := tbl.GetColumnsByPosition()
+	var colNames []string
+	for _, col := range cols {
+		colNames = append(colNames, fmt.Sprintf("`%s`", col.Name))
+	}
+	tuples := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(cols))
+		for j, col := range cols {
+			values[j] = d.escapedValue(col, row[col.Name])
+		}
+		tuples[i] = "(" + strings.Join(values, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES %s;", tbl.Name, strings.Join(colNames, ", "), strings.Join(tuples, ", "))
+}
+
+func (d *mysqlDialect) GenerateBatchDeleteSql(tbl *conn.Table, rows []conn.Record) string {
+	keys := tbl.GetRowKeyColumns()
+	var stmts, tuples []string
+	for _, row := range rows {
+		if len(keys) == 0 || slices.ContainsFunc(keys, func(k string) bool { return row[k] == nil }) {
+			stmts = append(stmts, d.GenerateDeleteSql(tbl, row))
+			continue
+		}
+		values := make([]string, len(keys))
+		for i, k := range keys {
+			values[i] = d.escapedValue(tbl.Columns[k], row[k])
+		}
+		if len(keys) == 1 {
+			tuples = append(tuples, values[0])
+		} else {
+			tuples = append(tuples, "("+strings.Join(values, ", ")+")")
+		}
+	}
+	if len(tuples) > 0 {
+		keyList := utils.JoinWrap(keys, "`", ", ")
+		if len(keys) > 1 {
+			keyList = "(" + keyList + ")"
+		}
+		stmts = append(stmts, fmt.Sprintf("DELETE FROM `%s` WHERE %s IN (%s);", tbl.Name, keyList, strings.Join(tuples, ", ")))
+	}
+	return strings.Join(stmts, "\n")
+}
+
+func (d *mysqlDialect) GenerateCopySql(tbl *conn.Table, dataFile string) string {
+	return fmt.Sprintf("LOAD DATA LOCAL INFILE '%s' INTO TABLE `%s` CHARACTER SET utf8mb4 (%s);",
+		strings.ReplaceAll(dataFile, "'", "''"), tbl.Name, utils.JoinWrap(tbl.GetColumnNamesByPosition(), "`", ", "))
+}
+
+func (d *mysqlDialect) GenerateCopyData(tbl *conn.Table, rows []conn.Record) string {
+	cols := tbl.GetColumnsByPosition()
+	var sb strings.Builder
+	for _, row := range rows {
+		for i, col := range cols {
+			if i > 0 {
+				sb.WriteByte('\t')
+			}
+			sb.WriteString(d.copyValue(row[col.Name]))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// copyValue 按 LOAD DATA 默认格式输出字段值
+func (d *mysqlDialect) copyValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return `\N`
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999")
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return utils.EscapeCopyText(string(v))
+	default:
+		return utils.EscapeCopyText(fmt.Sprintf("%v", v))
+	}
 }
 
 func (d *mysqlDialect) GenerateCreateIndexSql(t *conn.Table, idx *conn.Index) string {
@@ -319,32 +501,38 @@ func (d *mysqlDialect) GenerateAlterColumnSql(t *conn.Table, oldCol, newCol *con
 	return ddl.String()
 }
 
-func (d *mysqlDialect) escapedValue(dataType string, val any) string {
-	dt := strings.ToLower(dataType)
+var mysqlStringReplacer = strings.NewReplacer(
+	"\\", "\\\\", "'", "''", "\x00", "\\0", "\n", "\\n", "\r", "\\r", "\t", "\\t", "\b", "\\b", "\x1a", "\\Z",
+)
+
+// escapedValue 生成值的 SQL 字面量
+// 数值、布尔列的数值原样输出，二进制列使用十六进制字面量，时间按不带时区的格式输出，
+// 其余值(包括驱动以 []byte 返回的文本与 DECIMAL)一律按字符串转义后加引号
+func (d *mysqlDialect) escapedValue(col *conn.Column, val any) string {
 	if val == nil {
 		return "NULL"
-	} else if strings.HasPrefix(dt, "char") || strings.HasPrefix(dt, "varchar") ||
-		strings.HasPrefix(dt, "text") || strings.HasPrefix(dt, "json") {
-		// 将值转换为字符串并进行转义
-		strVal := fmt.Sprintf("%v", val)
-		// 转义反斜杠：\ -> \\
-		escaped := strings.ReplaceAll(strVal, "\\", "\\\\")
-		// 转义单引号：' -> ''
-		escaped = strings.ReplaceAll(escaped, "'", "''")
-		// 转义换行符：\n -> \\n
-		escaped = strings.ReplaceAll(escaped, "\n", "\\n")
-		// 转义回车符：\r -> \\r
-		escaped = strings.ReplaceAll(escaped, "\r", "\\r")
-		// 转义制表符：\t -> \\t
-		escaped = strings.ReplaceAll(escaped, "\t", "\\t")
-		// 转义退格符：\b -> \\b
-		escaped = strings.ReplaceAll(escaped, "\b", "\\b")
-		// 转义换页符：\f -> \\f
-		escaped = strings.ReplaceAll(escaped, "\f", "\\f")
-		return fmt.Sprintf("'%s'", escaped)
-	} else if strings.HasPrefix(dt, "date") || strings.HasPrefix(dt, "time") || strings.HasPrefix(dt, "enum") || strings.HasPrefix(dt, "set") || strings.HasPrefix(dt, "blob") {
-		return fmt.Sprintf("'%v'", val)
-	} else {
-		return fmt.Sprintf("%v", val)
 	}
+	kind := col.Kind()
+	switch v := val.(type) {
+	case []byte:
+		if kind == conn.ColumnKindBinary {
+			return "X'" + hex.EncodeToString(v) + "'"
+		}
+		val = string(v)
+	case time.Time:
+		if strings.EqualFold(col.DataType, "date") {
+			return "'" + v.Format(time.DateOnly) + "'"
+		}
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	}
+	if kind == conn.ColumnKindNumeric || kind == conn.ColumnKindBool || kind == conn.ColumnKindUnknown {
+		if lit, ok := utils.NumberLiteral(val); ok {
+			return lit
+		}
+	}
+	// 驱动以文本返回的数值(如 DECIMAL)原样输出
+	if s, ok := val.(string); ok && kind == conn.ColumnKindNumeric && utils.IsNumericLiteral(s) {
+		return s
+	}
+	return "'" + mysqlStringReplacer.Replace(fmt.Sprintf("%v", val)) + "'"
 }
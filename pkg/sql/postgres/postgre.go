@@ -1,9 +1,11 @@
 package postgres
 
 import (
+	"encoding/hex"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/jacktea/data-smith/pkg/conn"
 	"github.com/jacktea/data-smith/pkg/utils"
@@ -25,28 +27,42 @@ func (d *postgreDialect) GenerateInsertSql(tbl *conn.Table, row conn.Record) str
 	for _, col := range cols {
 		colNames = append(colNames, fmt.Sprintf("\"%s\"", col.Name))
 		val := row[col.Name]
-		values = append(values, d.escapedValue(col.DataType, val))
+		values = append(values, d.escapedValue(col, val))
 	}
 	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", tbl.Name, strings.Join(colNames, ", "), strings.Join(values, ", "))
 }
 
 func (d *postgreDialect) GenerateDeleteSql(tbl *conn.Table, row conn.Record) string {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		// 没有行键时按整行匹配，每条语句只删除一行，重复行由多条语句逐条删除
+		where := d.rowConditions(tbl, row, tbl.GetColumnNamesByPosition())
+		return fmt.Sprintf("DELETE FROM %s WHERE ctid = (SELECT ctid FROM %s WHERE %s LIMIT 1);", tbl.Name, tbl.Name, strings.Join(where, " AND "))
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s;", tbl.Name, strings.Join(d.rowConditions(tbl, row, keys), " AND "))
+}
+
+// rowConditions 生成按列值定位行的条件
+func (d *postgreDialect) rowConditions(tbl *conn.Table, row conn.Record, cols []string) []string {
 	var where []string
-	for _, k := range tbl.PrimaryKey.Columns {
+	for _, k := range cols {
 		col := tbl.Columns[k]
 		val := row[k]
 		if val == nil {
 			where = append(where, fmt.Sprintf("\"%s\" IS NULL", k))
+		} else if col.Kind() == conn.ColumnKindJSON {
+			// json 类型没有等值运算符，按文本比较
+			where = append(where, fmt.Sprintf("\"%s\"::text = %v", k, d.escapedValue(col, val)))
 		} else {
-			where = append(where, fmt.Sprintf("\"%s\" = %v", k, d.escapedValue(col.DataType, val)))
+			where = append(where, fmt.Sprintf("\"%s\" = %v", k, d.escapedValue(col, val)))
 		}
 	}
-	return fmt.Sprintf("DELETE FROM %s WHERE %s;", tbl.Name, strings.Join(where, " AND "))
+	return where
 }
 
 func (d *postgreDialect) GenerateUpdateSql(tbl *conn.Table, row conn.Record, updateCols []string) string {
 	var set, where []string
-	pks := tbl.PrimaryKey.Columns
+	pks := tbl.GetRowKeyColumns()
 	if len(updateCols) == 0 {
 		updateCols = tbl.GetColumns()
 	}
@@ -56,18 +72,196 @@ func (d *postgreDialect) GenerateUpdateSql(tbl *conn.Table, row conn.Record, upd
 		}
 		col := tbl.Columns[c]
 		val := row[c]
-		set = append(set, fmt.Sprintf("\"%s\" = %s", c, d.escapedValue(col.DataType, val)))
+		set = append(set, fmt.Sprintf("\"%s\" = %s", c, d.escapedValue(col, val)))
 	}
-	for _, k := range pks {
-		col := tbl.Columns[k]
-		val := row[k]
-		if val == nil {
-			where = append(where, fmt.Sprintf("\"%s\" IS NULL", k))
+	where = d.rowConditions(tbl, row, pks)
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", tbl.Name, strings.Join(set, ", "), strings.Join(where, " AND "))
+}
+
+func (d *postgreDialect) GenerateUpsertSql(tbl *conn.Table, row conn.Record, updateCols []string) string {
+	insert := d.GenerateInsertSql(tbl, row)
+	conflict := d.conflictClause(tbl, updateCols)
+	if conflict == "" {
+		return insert
+	}
+	return strings.TrimSuffix(insert, ";") + conflict + ";"
+}
+
+// conflictClause 生成 ON CONFLICT 子句，没有行键时返回空
+func (d *postgreDialect) conflictClause(tbl *conn.Table, updateCols []string) string {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		return ""
+	}
+	if len(updateCols) == 0 {
+		updateCols = tbl.GetColumnNamesByPosition()
+	}
+	var set []string
+	for _, c := range updateCols {
+		if slices.Contains(keys, c) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("\"%s\" = EXCLUDED.\"%s\"", c, c))
+	}
+	action := "DO NOTHING"
+	if len(set) > 0 {
+		action = "DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) %s", utils.JoinWrap(keys, "\"", ", "), action)
+}
+
+func (d *postgreDialect) GenerateInsertStmt(tbl *conn.Table) (string, []string) {
+	cols := tbl.GetColumnNamesByPosition()
+	params := make([]string, len(cols))
+	for i := range cols {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tbl.Name, utils.JoinWrap(cols, "\"", ", "), strings.Join(params, ", ")), cols
+}
+
+func (d *postgreDialect) GenerateDeleteStmt(tbl *conn.Table) (string, []string) {
+	keys := tbl.GetRowKeyColumns()
+	if len(keys) == 0 {
+		cols := tbl.GetColumnNamesByPosition()
+		where := d.paramConditions(tbl, cols, 1, true)
+		return fmt.Sprintf("DELETE FROM %s WHERE ctid = (SELECT ctid FROM %s WHERE %s LIMIT 1)", tbl.Name, tbl.Name, where), cols
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", tbl.Name, d.paramConditions(tbl, keys, 1, false)), keys
+}
+
+func (d *postgreDialect) GenerateUpdateStmt(tbl *conn.Table, updateCols []string) (string, []string) {
+	keys := tbl.GetRowKeyColumns()
+	if len(updateCols) == 0 {
+		updateCols = tbl.GetColumnNamesByPosition()
+	}
+	var set, params []string
+	for _, c := range updateCols {
+		if slices.Contains(keys, c) {
+			continue
+		}
+		params = append(params, c)
+		set = append(set, fmt.Sprintf("\"%s\" = $%d", c, len(params)))
+	}
+	where := d.paramConditions(tbl, keys, len(params)+1, false)
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", tbl.Name, strings.Join(set, ", "), where), append(params, keys...)
+}
+
+func (d *postgreDialect) GenerateUpsertStmt(tbl *conn.Table, updateCols []string) (string, []string) {
+	insert, cols := d.GenerateInsertStmt(tbl)
+	return insert + d.conflictClause(tbl, updateCols), cols
+}
+
+func (d *postgreDialect) GenerateDisableFKChecksSql() (string, string) {
+	// 仅在当前事务内生效，需要超级用户权限
+	return "SET LOCAL session_replication_role = replica", ""
+}
+
+// paramConditions 生成参数化的定位条件，nullSafe 为 true 时使用 IS NOT DISTINCT FROM 匹配 NULL
+func (d *postgreDialect) paramConditions(tbl *conn.Table, cols []string, start int, nullSafe bool) string {
+	op := "="
+	if nullSafe {
+		op = "IS NOT DISTINCT FROM"
+	}
+	where := make([]string, len(cols))
+	for i, c := range cols {
+		if tbl.GetColumn(c).Kind() == conn.ColumnKindJSON {
+			where[i] = fmt.Sprintf("\"%s\"::text %s $%d", c, op, start+i)
 		} else {
-			where = append(where, fmt.Sprintf("\"%s\" = %v", k, d.escapedValue(col.DataType, val)))
+			where[i] = fmt.Sprintf("\"%s\" %s $%d", c, op, start+i)
 		}
 	}
-	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", tbl.Name, strings.Join(set, ", "), strings.Join(where, " AND "))
+	return strings.Join(where, " AND ")
+}
+
+func (d *postgreDialect) GenerateBatchInsertSql(tbl *conn.Table, rows []conn.Record) string {
+	cols := tbl.GetColumnsByPosition()
+	var colNames []string
+	for _, col := range cols {
+		colNames = append(colNames, fmt.Sprintf("\"%s\"", col.Name))
+	}
+	tuples := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(cols))
+		for j, col := range cols {
+			values[j] = d.escapedValue(col, row[col.Name])
+		}
+		tuples[i] = "(" + strings.Join(values, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s;", tbl.Name, strings.Join(colNames, ", "), strings.Join(tuples, ", "))
+}
+
+func (d *postgreDialect) GenerateBatchDeleteSql(tbl *conn.Table, rows []conn.Record) string {
+	keys := tbl.GetRowKeyColumns()
+	var stmts, tuples []string
+	for _, row := range rows {
+		if len(keys) == 0 || slices.ContainsFunc(keys, func(k string) bool { return row[k] == nil }) {
+			stmts = append(stmts, d.GenerateDeleteSql(tbl, row))
+			continue
+		}
+		values := make([]string, len(keys))
+		for i, k := range keys {
+			values[i] = d.escapedValue(tbl.Columns[k], row[k])
+		}
+		if len(keys) == 1 {
+			tuples = append(tuples, values[0])
+		} else {
+			tuples = append(tuples, "("+strings.Join(values, ", ")+")")
+		}
+	}
+	if len(tuples) > 0 {
+		keyList := utils.JoinWrap(keys, "\"", ", ")
+		if len(keys) > 1 {
+			keyList = "(" + keyList + ")"
+		}
+		stmts = append(stmts, fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s);", tbl.Name, keyList, strings.Join(tuples, ", ")))
+	}
+	return strings.Join(stmts, "\n")
+}
+
+func (d *postgreDialect) GenerateCopySql(tbl *conn.Table, dataFile string) string {
+	cols := utils.JoinWrap(tbl.GetColumnNamesByPosition(), "\"", ", ")
+	if dataFile == "" {
+		return fmt.Sprintf("COPY %s (%s) FROM stdin;", tbl.Name, cols)
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM '%s';", tbl.Name, cols, strings.ReplaceAll(dataFile, "'", "''"))
+}
+
+func (d *postgreDialect) GenerateCopyData(tbl *conn.Table, rows []conn.Record) string {
+	cols := tbl.GetColumnsByPosition()
+	var sb strings.Builder
+	for _, row := range rows {
+		for i, col := range cols {
+			if i > 0 {
+				sb.WriteByte('\t')
+			}
+			sb.WriteString(d.copyValue(col, row[col.Name]))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// copyValue 按 COPY 文本格式输出字段值
+func (d *postgreDialect) copyValue(col *conn.Column, val any) string {
+	switch v := val.(type) {
+	case nil:
+		return `\N`
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999Z07:00")
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case []byte:
+		if col.Kind() == conn.ColumnKindBinary {
+			// bytea 使用十六进制格式，反斜杠需转义
+			return `\\x` + hex.EncodeToString(v)
+		}
+		return utils.EscapeCopyText(string(v))
+	default:
+		return utils.EscapeCopyText(fmt.Sprintf("%v", v))
+	}
 }
 
 func (d *postgreDialect) GenerateCreateIndexSql(t *conn.Table, idx *conn.Index) string {
@@ -364,31 +558,58 @@ func (d *postgreDialect) GenerateAlterColumnSql(t *conn.Table, oldCol, newCol *c
 	return ddl.String()
 }
 
-func (d *postgreDialect) escapedValue(dataType string, val any) string {
-	dt := strings.ToLower(dataType)
+var postgresEscapeReplacer = strings.NewReplacer(
+	"\\", "\\\\", "'", "''", "\n", "\\n", "\r", "\\r", "\t", "\\t", "\b", "\\b", "\f", "\\f",
+)
+
+// escapedValue 生成值的 SQL 字面量
+// 数值、布尔列的数值原样输出，bytea 使用十六进制格式，时间按列是否带时区选择格式，
+// 其余值(包括驱动以 []byte 返回的 numeric、uuid、json)一律按字符串加引号；
+// 含反斜杠或控制字符时使用 E 前缀的转义字符串，不受 standard_conforming_strings 影响
+func (d *postgreDialect) escapedValue(col *conn.Column, val any) string {
 	if val == nil {
 		return "NULL"
-	} else if strings.Contains(dt, "char") || strings.Contains(dt, "text") || strings.Contains(dt, "json") {
-		// 将值转换为字符串并进行转义
-		strVal := fmt.Sprintf("%v", val)
-		// 转义反斜杠：\ -> \\
-		escaped := strings.ReplaceAll(strVal, "\\", "\\\\")
-		// 转义单引号：' -> ''
-		escaped = strings.ReplaceAll(escaped, "'", "''")
-		// 转义换行符：\n -> \\n
-		escaped = strings.ReplaceAll(escaped, "\n", "\\n")
-		// 转义回车符：\r -> \\r
-		escaped = strings.ReplaceAll(escaped, "\r", "\\r")
-		// 转义制表符：\t -> \\t
-		escaped = strings.ReplaceAll(escaped, "\t", "\\t")
-		// 转义退格符：\b -> \\b
-		escaped = strings.ReplaceAll(escaped, "\b", "\\b")
-		// 转义换页符：\f -> \\f
-		escaped = strings.ReplaceAll(escaped, "\f", "\\f")
-		return fmt.Sprintf("'%s'", escaped)
-	} else if strings.Contains(dt, "date") || strings.Contains(dt, "time") || strings.Contains(dt, "uuid") {
-		return fmt.Sprintf("'%v'", val)
-	} else {
-		return fmt.Sprintf("%v", val)
 	}
+	kind := col.Kind()
+	switch v := val.(type) {
+	case []byte:
+		if kind == conn.ColumnKindBinary {
+			return `E'\\x` + hex.EncodeToString(v) + "'"
+		}
+		val = string(v)
+	case time.Time:
+		return "'" + d.timeLiteral(col, v) + "'"
+	}
+	if kind == conn.ColumnKindNumeric || kind == conn.ColumnKindBool || kind == conn.ColumnKindUnknown {
+		if lit, ok := utils.NumberLiteral(val); ok {
+			return lit
+		}
+	}
+	// 驱动以文本返回的数值(如 DECIMAL)原样输出
+	if s, ok := val.(string); ok && kind == conn.ColumnKindNumeric && utils.IsNumericLiteral(s) {
+		return s
+	}
+	s := fmt.Sprintf("%v", val)
+	if strings.ContainsAny(s, "\\\n\r\t\b\f") {
+		return "E'" + postgresEscapeReplacer.Replace(s) + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// timeLiteral 按列类型格式化时间值，只有带时区的列才输出时区偏移
+func (d *postgreDialect) timeLiteral(col *conn.Column, t time.Time) string {
+	dt := strings.ToLower(strings.TrimSpace(col.DataType))
+	var layout string
+	switch {
+	case dt == "date":
+		layout = time.DateOnly
+	case strings.HasPrefix(dt, "time") && !strings.HasPrefix(dt, "timestamp"):
+		layout = "15:04:05.999999"
+	default:
+		layout = "2006-01-02 15:04:05.999999"
+	}
+	if col.HasTimeZone() && dt != "date" {
+		layout += "Z07:00"
+	}
+	return t.Format(layout)
 }
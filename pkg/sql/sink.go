@@ -0,0 +1,294 @@
+package sql
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/diff"
+	"github.com/jacktea/data-smith/pkg/logger"
+)
+
+const (
+	defaultSinkFlushEvery = 1000
+	defaultSinkBatchSize  = 500
+)
+
+// InsertFormat 新增行的输出格式
+type InsertFormat string
+
+const (
+	// InsertFormatRow 每行一条 INSERT
+	InsertFormatRow InsertFormat = "row"
+	// InsertFormatBatch 多行 INSERT ... VALUES (...), (...)
+	InsertFormatBatch InsertFormat = "batch"
+	// InsertFormatCopy PostgreSQL COPY ... FROM stdin，MySQL LOAD DATA 与 TSV 数据文件
+	InsertFormatCopy InsertFormat = "copy"
+)
+
+// SqlSinkOptions SQL 差异输出参数
+type SqlSinkOptions struct {
+	// UpdateCols 生成 UPDATE 语句时更新的列，为空时更新全部列
+	UpdateCols []string
+	// FlushEvery 每写入多少行差异刷新一次缓冲并输出进度
+	FlushEvery int
+	// TmpDir INSERT/UPDATE 语句的暂存目录，为空时使用系统临时目录
+	TmpDir string
+	// KeyColumns 生成 UPDATE/DELETE 条件使用的键列，为空时使用表的主键或非空唯一索引
+	KeyColumns []string
+	// Upsert 为 true 时新增与修改的行均逐行输出插入或更新语句，脚本可重复执行
+	Upsert bool
+	// InsertFormat 新增行的输出格式，为空时逐行输出；非逐行格式同时按键分组输出 DELETE
+	InsertFormat InsertFormat
+	// BatchSize 非逐行格式下每条 INSERT/COPY/DELETE 包含的最大行数
+	BatchSize int
+	// CopyDataFile copy 格式的数据文件，为空时数据以 COPY ... FROM stdin 内联到脚本(仅 PostgreSQL 支持)
+	CopyDataFile string
+}
+
+// SqlDiffSink 将差异直接转换为 SQL 写出，内存占用只取决于缓冲区大小
+// 输出顺序与原有脚本一致：先 DELETE，再 INSERT，最后 UPDATE。
+// DELETE 直接写入目标，INSERT/UPDATE 先写入临时文件，Close 时按顺序追加。
+type SqlDiffSink struct {
+	dialect IDialect
+	tbl     *conn.Table
+	opts    SqlSinkOptions
+
+	out       *bufio.Writer
+	inserts   *spoolFile
+	updates   *spoolFile
+	stats     diff.DiffStats
+	unflushed int
+
+	pendingInserts []conn.Record
+	pendingDeletes []conn.Record
+	copyFile       *os.File
+	copySegment    int
+}
+
+func NewSqlDiffSink(w io.Writer, dialect IDialect, tbl *conn.Table, opts SqlSinkOptions) *SqlDiffSink {
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = defaultSinkFlushEvery
+	}
+	if opts.InsertFormat == "" {
+		opts.InsertFormat = InsertFormatRow
+	}
+	if opts.BatchSize <= 1 {
+		opts.BatchSize = defaultSinkBatchSize
+	}
+	if len(opts.KeyColumns) > 0 {
+		keyTbl := *tbl
+		keyTbl.PrimaryKey = &conn.PrimaryKey{Columns: opts.KeyColumns}
+		tbl = &keyTbl
+	}
+	return &SqlDiffSink{
+		dialect: dialect,
+		tbl:     tbl,
+		opts:    opts,
+		out:     bufio.NewWriter(w),
+		inserts: &spoolFile{dir: opts.TmpDir},
+		updates: &spoolFile{dir: opts.TmpDir},
+	}
+}
+
+func (s *SqlDiffSink) Write(diffType diff.DiffType, srcRow, tgtRow conn.Record) error {
+	var err error
+	switch diffType {
+	case diff.DiffTypeDrop:
+		if s.batched() {
+			s.pendingDeletes = append(s.pendingDeletes, tgtRow)
+			if len(s.pendingDeletes) >= s.opts.BatchSize {
+				err = s.flushDeletes()
+			}
+		} else {
+			_, err = s.out.WriteString(s.dialect.GenerateDeleteSql(s.tbl, tgtRow) + "\n")
+		}
+	case diff.DiffTypeAdd:
+		if s.opts.Upsert {
+			err = s.inserts.WriteString(s.dialect.GenerateUpsertSql(s.tbl, srcRow, s.opts.UpdateCols) + "\n")
+		} else if s.batched() {
+			s.pendingInserts = append(s.pendingInserts, srcRow)
+			if len(s.pendingInserts) >= s.opts.BatchSize {
+				err = s.flushInserts()
+			}
+		} else {
+			err = s.inserts.WriteString(s.dialect.GenerateInsertSql(s.tbl, srcRow) + "\n")
+		}
+	case diff.DiffTypeModify:
+		if s.opts.Upsert {
+			err = s.updates.WriteString(s.dialect.GenerateUpsertSql(s.tbl, srcRow, s.opts.UpdateCols) + "\n")
+		} else {
+			err = s.updates.WriteString(s.dialect.GenerateUpdateSql(s.tbl, srcRow, s.opts.UpdateCols) + "\n")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.stats.Add(diffType)
+	s.unflushed++
+	if s.unflushed >= s.opts.FlushEvery {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *SqlDiffSink) batched() bool {
+	return s.opts.InsertFormat != InsertFormatRow
+}
+
+// flushDeletes 将缓存的删除行按键分组输出
+func (s *SqlDiffSink) flushDeletes() error {
+	if len(s.pendingDeletes) == 0 {
+		return nil
+	}
+	_, err := s.out.WriteString(s.dialect.GenerateBatchDeleteSql(s.tbl, s.pendingDeletes) + "\n")
+	s.pendingDeletes = s.pendingDeletes[:0]
+	return err
+}
+
+// flushInserts 将缓存的新增行输出为多行 INSERT 或批量导入数据
+func (s *SqlDiffSink) flushInserts() error {
+	if len(s.pendingInserts) == 0 {
+		return nil
+	}
+	rows := s.pendingInserts
+	s.pendingInserts = s.pendingInserts[:0]
+	if s.opts.InsertFormat == InsertFormatBatch {
+		return s.inserts.WriteString(s.dialect.GenerateBatchInsertSql(s.tbl, rows) + "\n")
+	}
+	data := s.dialect.GenerateCopyData(s.tbl, rows)
+	if s.opts.CopyDataFile == "" {
+		return s.inserts.WriteString(s.dialect.GenerateCopySql(s.tbl, "") + "\n" + data + "\\.\n")
+	}
+	if s.copyFile == nil {
+		f, err := os.Create(s.copyDataFile())
+		if err != nil {
+			return err
+		}
+		s.copyFile = f
+	}
+	_, err := s.copyFile.WriteString(data)
+	return err
+}
+
+// copyDataFile 当前片段的数据文件，第一个片段使用 CopyDataFile，之后的片段追加序号
+func (s *SqlDiffSink) copyDataFile() string {
+	if s.copySegment == 0 {
+		return s.opts.CopyDataFile
+	}
+	ext := filepath.Ext(s.opts.CopyDataFile)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(s.opts.CopyDataFile, ext), s.copySegment, ext)
+}
+
+// Stats 返回已写出的差异统计
+func (s *SqlDiffSink) Stats() diff.DiffStats {
+	return s.stats
+}
+
+func (s *SqlDiffSink) flush() error {
+	s.unflushed = 0
+	if err := s.out.Flush(); err != nil {
+		return err
+	}
+	if err := s.inserts.Flush(); err != nil {
+		return err
+	}
+	if err := s.updates.Flush(); err != nil {
+		return err
+	}
+	logger.Infof("表 %s 已输出差异: 新增 %d, 删除 %d, 修改 %d", s.tbl.Name, s.stats.Added, s.stats.Dropped, s.stats.Modified)
+	return nil
+}
+
+// Checkpoint 实现 diff.CheckpointSink
+// 输出缓存的批量语句，将暂存的 INSERT/UPDATE 追加到输出并刷新缓冲，之后的差异从新的片段开始
+func (s *SqlDiffSink) Checkpoint() error {
+	if err := s.flushDeletes(); err != nil {
+		return err
+	}
+	if err := s.flushInserts(); err != nil {
+		return err
+	}
+	if s.copyFile != nil {
+		if err := s.copyFile.Close(); err != nil {
+			return err
+		}
+		if _, err := s.out.WriteString(s.dialect.GenerateCopySql(s.tbl, s.copyFile.Name()) + "\n"); err != nil {
+			return err
+		}
+		s.copyFile = nil
+		s.copySegment++
+	}
+	if err := s.inserts.CopyTo(s.out); err != nil {
+		return err
+	}
+	s.inserts.Remove()
+	if err := s.updates.CopyTo(s.out); err != nil {
+		return err
+	}
+	s.updates.Remove()
+	s.unflushed = 0
+	return s.out.Flush()
+}
+
+// Close 输出剩余的差异并清理临时文件
+func (s *SqlDiffSink) Close() error {
+	defer s.inserts.Remove()
+	defer s.updates.Remove()
+	return s.Checkpoint()
+}
+
+// spoolFile 按需创建的临时文件
+type spoolFile struct {
+	dir  string
+	file *os.File
+	w    *bufio.Writer
+}
+
+func (f *spoolFile) WriteString(str string) error {
+	if f.file == nil {
+		file, err := os.CreateTemp(f.dir, ".datasmith_spool_*.sql")
+		if err != nil {
+			return err
+		}
+		f.file = file
+		f.w = bufio.NewWriter(file)
+	}
+	_, err := f.w.WriteString(str)
+	return err
+}
+
+func (f *spoolFile) Flush() error {
+	if f.w == nil {
+		return nil
+	}
+	return f.w.Flush()
+}
+
+func (f *spoolFile) CopyTo(w io.Writer) error {
+	if f.file == nil {
+		return nil
+	}
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, f.file)
+	return err
+}
+
+func (f *spoolFile) Remove() {
+	if f.file == nil {
+		return
+	}
+	f.file.Close()
+	os.Remove(f.file.Name())
+	f.file = nil
+	f.w = nil
+}
@@ -0,0 +1,299 @@
+package sql
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacktea/data-smith/pkg/conn"
+	"github.com/jacktea/data-smith/pkg/consts"
+	"github.com/jacktea/data-smith/pkg/diff"
+)
+
+func TestSqlDiffSinkOrder(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":  {Name: "id", DataType: "integer", Position: 1},
+			"val": {Name: "val", DataType: "text", Position: 2},
+		},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+	}
+	var out strings.Builder
+	sink := NewSqlDiffSink(&out, NewDialect(consts.DBTypePostgres), tbl, SqlSinkOptions{FlushEvery: 1, TmpDir: t.TempDir()})
+	writes := []struct {
+		diffType diff.DiffType
+		src, tgt conn.Record
+	}{
+		{diff.DiffTypeAdd, conn.Record{"id": 1, "val": "a"}, nil},
+		{diff.DiffTypeModify, conn.Record{"id": 2, "val": "b"}, conn.Record{"id": 2, "val": "B"}},
+		{diff.DiffTypeDrop, nil, conn.Record{"id": 3, "val": "c"}},
+	}
+	for _, w := range writes {
+		if err := sink.Write(w.diffType, w.src, w.tgt); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	expect := `DELETE FROM t WHERE "id" = 3;
+INSERT INTO t ("id", "val") VALUES (1, 'a');
+UPDATE t SET "val" = 'b' WHERE "id" = 2;
+`
+	if out.String() != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+	if stats := sink.Stats(); stats.Total() != 3 {
+		t.Errorf("stats = %+v, expect 3 rows", stats)
+	}
+}
+
+func TestSqlDiffSinkWithoutPrimaryKey(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"code": {Name: "code", DataType: "varchar", Position: 1},
+			"val":  {Name: "val", DataType: "text", Position: 2, Nullable: true},
+		},
+	}
+	tests := []struct {
+		dbType consts.DBType
+		expect string
+	}{
+		{consts.DBTypePostgres, `DELETE FROM t WHERE ctid = (SELECT ctid FROM t WHERE "code" = 'a' AND "val" IS NULL LIMIT 1);` + "\n"},
+		{consts.DBTypeMySQL, "DELETE FROM `t` WHERE `code` = 'a' AND `val` IS NULL LIMIT 1;\n"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		sink := NewSqlDiffSink(&out, NewDialect(tt.dbType), tbl, SqlSinkOptions{TmpDir: t.TempDir()})
+		if err := sink.Write(diff.DiffTypeDrop, nil, conn.Record{"code": "a", "val": nil}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if out.String() != tt.expect {
+			t.Errorf("%s: got %q, expect %q", tt.dbType, out.String(), tt.expect)
+		}
+	}
+
+	// 通过唯一索引定位行
+	tbl.Indexes = map[string]*conn.Index{"uk_code": {Name: "uk_code", Columns: []string{"code"}, Unique: true}}
+	var out strings.Builder
+	sink := NewSqlDiffSink(&out, NewDialect(consts.DBTypePostgres), tbl, SqlSinkOptions{TmpDir: t.TempDir()})
+	sink.Write(diff.DiffTypeModify, conn.Record{"code": "a", "val": "x"}, conn.Record{"code": "a", "val": nil})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if expect := `UPDATE t SET "val" = 'x' WHERE "code" = 'a';` + "\n"; out.String() != expect {
+		t.Errorf("got %q, expect %q", out.String(), expect)
+	}
+}
+
+func TestSqlDiffSinkUpsert(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":  {Name: "id", DataType: "integer", Position: 1},
+			"val": {Name: "val", DataType: "text", Position: 2},
+		},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+	}
+	tests := []struct {
+		dbType consts.DBType
+		expect string
+	}{
+		{consts.DBTypePostgres, `INSERT INTO t ("id", "val") VALUES (1, 'a') ON CONFLICT ("id") DO UPDATE SET "val" = EXCLUDED."val";
+INSERT INTO t ("id", "val") VALUES (2, 'b') ON CONFLICT ("id") DO UPDATE SET "val" = EXCLUDED."val";
+`},
+		{consts.DBTypeMySQL, "INSERT INTO `t` (`id`, `val`) VALUES (1, 'a') ON DUPLICATE KEY UPDATE `val` = VALUES(`val`);\n" +
+			"INSERT INTO `t` (`id`, `val`) VALUES (2, 'b') ON DUPLICATE KEY UPDATE `val` = VALUES(`val`);\n"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		sink := NewSqlDiffSink(&out, NewDialect(tt.dbType), tbl, SqlSinkOptions{TmpDir: t.TempDir(), Upsert: true})
+		sink.Write(diff.DiffTypeModify, conn.Record{"id": 2, "val": "b"}, conn.Record{"id": 2, "val": "B"})
+		sink.Write(diff.DiffTypeAdd, conn.Record{"id": 1, "val": "a"}, nil)
+		if err := sink.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if out.String() != tt.expect {
+			t.Errorf("%s: got:\n%s\nexpect:\n%s", tt.dbType, out.String(), tt.expect)
+		}
+	}
+}
+
+func TestSqlDiffSinkBatchFormats(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":  {Name: "id", DataType: "integer", Position: 1},
+			"val": {Name: "val", DataType: "text", Position: 2, Nullable: true},
+		},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"id"}},
+	}
+	writeAll := func(sink *SqlDiffSink) {
+		for i := 1; i <= 3; i++ {
+			sink.Write(diff.DiffTypeDrop, nil, conn.Record{"id": 10 + i, "val": "x"})
+		}
+		sink.Write(diff.DiffTypeAdd, conn.Record{"id": 1, "val": "a\tb"}, nil)
+		sink.Write(diff.DiffTypeAdd, conn.Record{"id": 2, "val": nil}, nil)
+		sink.Write(diff.DiffTypeAdd, conn.Record{"id": 3, "val": "c"}, nil)
+	}
+
+	var out strings.Builder
+	sink := NewSqlDiffSink(&out, NewDialect(consts.DBTypePostgres), tbl, SqlSinkOptions{
+		TmpDir: t.TempDir(), InsertFormat: InsertFormatBatch, BatchSize: 2,
+	})
+	writeAll(sink)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	expect := `DELETE FROM t WHERE "id" IN (11, 12);
+DELETE FROM t WHERE "id" IN (13);
+INSERT INTO t ("id", "val") VALUES (1, E'a\tb'), (2, NULL);
+INSERT INTO t ("id", "val") VALUES (3, 'c');
+`
+	if out.String() != expect {
+		t.Errorf("batch: got:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+
+	out.Reset()
+	sink = NewSqlDiffSink(&out, NewDialect(consts.DBTypePostgres), tbl, SqlSinkOptions{
+		TmpDir: t.TempDir(), InsertFormat: InsertFormatCopy, BatchSize: 10,
+	})
+	writeAll(sink)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	expect = "DELETE FROM t WHERE \"id\" IN (11, 12, 13);\n" +
+		"COPY t (\"id\", \"val\") FROM stdin;\n1\ta\\tb\n2\t\\N\n3\tc\n\\.\n"
+	if out.String() != expect {
+		t.Errorf("copy: got:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+
+	out.Reset()
+	dataFile := t.TempDir() + "/t.tsv"
+	sink = NewSqlDiffSink(&out, NewDialect(consts.DBTypeMySQL), tbl, SqlSinkOptions{
+		TmpDir: t.TempDir(), InsertFormat: InsertFormatCopy, BatchSize: 2, CopyDataFile: dataFile,
+	})
+	writeAll(sink)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	expect = "DELETE FROM `t` WHERE `id` IN (11, 12);\nDELETE FROM `t` WHERE `id` IN (13);\n" +
+		"LOAD DATA LOCAL INFILE '" + dataFile + "' INTO TABLE `t` CHARACTER SET utf8mb4 (`id`, `val`);\n"
+	if out.String() != expect {
+		t.Errorf("load data: got:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+	data, err := os.ReadFile(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "1\ta\\tb\n2\t\\N\n3\tc\n"; string(data) != expect {
+		t.Errorf("tsv: got %q, expect %q", data, expect)
+	}
+}
+
+func TestGenerateInsertSqlDriverValues(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":   {Name: "id", DataType: "decimal", Position: 1},
+			"name": {Name: "name", DataType: "varchar", Position: 2},
+			"data": {Name: "data", DataType: "blob", Position: 3},
+			"at":   {Name: "at", DataType: "datetime", Position: 4},
+			"day":  {Name: "day", DataType: "date", Position: 5},
+		},
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	// 驱动以 []byte 返回文本、DECIMAL 与二进制值
+	row := conn.Record{"id": []byte("12.50"), "name": []byte("bob's"), "data": []byte{0, 0xff}, "at": at, "day": at}
+	expect := "INSERT INTO `t` (`id`, `name`, `data`, `at`, `day`) VALUES (12.50, 'bob''s', X'00ff', '2024-01-02 03:04:05.6', '2024-01-02');"
+	if got := NewDialect(consts.DBTypeMySQL).GenerateInsertSql(tbl, row); got != expect {
+		t.Errorf("mysql: got\n%s\nexpect\n%s", got, expect)
+	}
+
+	tbl = &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"id":   {Name: "id", DataType: "numeric", Position: 1},
+			"uid":  {Name: "uid", DataType: "uuid", Position: 2},
+			"doc":  {Name: "doc", DataType: "jsonb", Position: 3},
+			"data": {Name: "data", DataType: "bytea", Position: 4},
+			"at":   {Name: "at", DataType: "timestamp without time zone", Position: 5},
+			"ts":   {Name: "ts", DataType: "timestamp with time zone", Position: 6},
+		},
+	}
+	row = conn.Record{
+		"id": []byte("12.50"), "uid": []byte("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"), "doc": []byte(`{"a": "it's"}`),
+		"data": []byte{0, 0xff}, "at": at, "ts": at.In(time.FixedZone("", 8*3600)),
+	}
+	expect = `INSERT INTO t ("id", "uid", "doc", "data", "at", "ts") VALUES (12.50, 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', '{"a": "it''s"}', E'\\x00ff', '2024-01-02 03:04:05.6', '2024-01-02 11:04:05.6+08:00');`
+	if got := NewDialect(consts.DBTypePostgres).GenerateInsertSql(tbl, row); got != expect {
+		t.Errorf("postgres: got\n%s\nexpect\n%s", got, expect)
+	}
+}
+
+func TestSqlDiffSinkUpsertDriverValues(t *testing.T) {
+	tests := []struct {
+		dbType consts.DBType
+		cols   map[string]*conn.Column
+		row    conn.Record
+		expect string
+	}{
+		{consts.DBTypeMySQL, map[string]*conn.Column{
+			"code":  {Name: "code", DataType: "varchar", Position: 1},
+			"price": {Name: "price", DataType: "decimal", Position: 2},
+		}, conn.Record{"code": []byte("a'b"), "price": []byte("1.50")},
+			"INSERT INTO `t` (`code`, `price`) VALUES ('a''b', 1.50) ON DUPLICATE KEY UPDATE `price` = VALUES(`price`);\n"},
+		{consts.DBTypePostgres, map[string]*conn.Column{
+			"code":  {Name: "code", DataType: "uuid", Position: 1},
+			"price": {Name: "price", DataType: "numeric", Position: 2},
+		}, conn.Record{"code": []byte("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"), "price": []byte("1.50")},
+			`INSERT INTO t ("code", "price") VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 1.50) ON CONFLICT ("code") DO UPDATE SET "price" = EXCLUDED."price";` + "\n"},
+	}
+	for _, tt := range tests {
+		tbl := &conn.Table{Name: "t", Columns: tt.cols, PrimaryKey: &conn.PrimaryKey{Columns: []string{"code"}}}
+		var out strings.Builder
+		sink := NewSqlDiffSink(&out, NewDialect(tt.dbType), tbl, SqlSinkOptions{TmpDir: t.TempDir(), Upsert: true})
+		// 驱动以 []byte 返回文本与数值
+		sink.Write(diff.DiffTypeAdd, tt.row, nil)
+		if err := sink.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if out.String() != tt.expect {
+			t.Errorf("%s: got:\n%s\nexpect:\n%s", tt.dbType, out.String(), tt.expect)
+		}
+	}
+}
+
+func TestSqlDiffSinkBatchDriverValues(t *testing.T) {
+	tbl := &conn.Table{
+		Name: "t",
+		Columns: map[string]*conn.Column{
+			"code": {Name: "code", DataType: "varchar", Position: 1},
+			"at":   {Name: "at", DataType: "datetime", Position: 2},
+		},
+		PrimaryKey: &conn.PrimaryKey{Columns: []string{"code"}},
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var out strings.Builder
+	sink := NewSqlDiffSink(&out, NewDialect(consts.DBTypeMySQL), tbl, SqlSinkOptions{
+		TmpDir: t.TempDir(), InsertFormat: InsertFormatBatch, BatchSize: 10,
+	})
+	// 驱动以 []byte 返回 VARCHAR，以 time.Time 返回 DATETIME
+	sink.Write(diff.DiffTypeDrop, nil, conn.Record{"code": []byte("x1"), "at": at})
+	sink.Write(diff.DiffTypeDrop, nil, conn.Record{"code": []byte("x'2"), "at": at})
+	sink.Write(diff.DiffTypeAdd, conn.Record{"code": []byte("a"), "at": at}, nil)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	expect := "DELETE FROM `t` WHERE `code` IN ('x1', 'x''2');\n" +
+		"INSERT INTO `t` (`code`, `at`) VALUES ('a', '2024-01-02 03:04:05');\n"
+	if out.String() != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+}
@@ -1,6 +1,11 @@
 package utils
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 func JoinWrap(arr []string, wrap, sep string) string {
 	if len(arr) == 0 {
@@ -15,3 +20,34 @@ func JoinPreSuf(arr []string, pre, suf, sep string) string {
 	}
 	return pre + strings.Join(arr, suf+sep+pre) + suf
 }
+
+var copyTextReplacer = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r", "\x00", "\\0")
+
+// EscapeCopyText 按 PostgreSQL COPY 文本格式(同 MySQL LOAD DATA 默认格式)转义字段值
+func EscapeCopyText(s string) string {
+	return copyTextReplacer.Replace(s)
+}
+
+var numericLiteralRe = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// IsNumericLiteral 判断文本能否作为 SQL 数值字面量原样输出
+func IsNumericLiteral(s string) bool {
+	return numericLiteralRe.MatchString(s)
+}
+
+// NumberLiteral 数值与布尔类型的值转换为 SQL 字面量，其他类型返回 false
+func NumberLiteral(val any) (string, bool) {
+	switch v := val.(type) {
+	case bool:
+		if v {
+			return "TRUE", true
+		}
+		return "FALSE", true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), true
+	case json.Number:
+		return v.String(), IsNumericLiteral(v.String())
+	default:
+		return "", false
+	}
+}
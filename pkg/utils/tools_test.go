@@ -53,3 +53,40 @@ func TestJoinPreSuf(t *testing.T) {
 		})
 	}
 }
+
+func TestEscapeCopyText(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect string
+	}{
+		{"plain", "plain"},
+		{"a\tb", `a\tb`},
+		{"line1\nline2\r", `line1\nline2\r`},
+		{`C:\dir`, `C:\\dir`},
+		{`\N`, `\\N`},
+	}
+	for _, tt := range tests {
+		if got := EscapeCopyText(tt.in); got != tt.expect {
+			t.Errorf("EscapeCopyText(%q) = %q, want %q", tt.in, got, tt.expect)
+		}
+	}
+}
+
+func TestNumberLiteral(t *testing.T) {
+	for _, s := range []string{"1", "-2.5", "+.5", "1e10", "12345678901234567890"} {
+		if !IsNumericLiteral(s) {
+			t.Errorf("%q should be numeric", s)
+		}
+	}
+	for _, s := range []string{"", "1; DROP TABLE t", "0x1F", "1 OR 1=1", "."} {
+		if IsNumericLiteral(s) {
+			t.Errorf("%q should not be numeric", s)
+		}
+	}
+	if lit, ok := NumberLiteral(true); !ok || lit != "TRUE" {
+		t.Errorf("bool literal = %q, %v", lit, ok)
+	}
+	if _, ok := NumberLiteral("1"); ok {
+		t.Error("string should not be a number literal")
+	}
+}